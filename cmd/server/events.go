@@ -0,0 +1,94 @@
+package main
+
+import (
+	"log"
+	"presentation-service/internal/chat"
+	"presentation-service/internal/chat/counter"
+	"presentation-service/internal/chat/moderation"
+	"presentation-service/internal/chat/quiz"
+	"presentation-service/internal/eventlog"
+	"presentation-service/internal/transcription"
+	"sync"
+)
+
+// Everything changed by logged events. Live events are applied one at a time,
+// and logged in the order they were applied, so that replaying the log
+// reproduces the live state.
+type eventState struct {
+	pollCounters  map[string]*counter.SendersByTokenCounter
+	quizzes       map[string]*quiz.Quiz
+	questions     *moderation.TextCollector
+	transcription *transcription.Broadcaster
+	eventLog      *eventlog.Log // Nil if events aren't logged
+	mutex         sync.Mutex
+}
+
+// Applies live and replayed events alike.
+func (s *eventState) apply(event eventlog.Event) error {
+	switch event.Kind {
+	case eventlog.KindChat:
+		if event.Message != nil {
+			message := *event.Message
+			if message.Time.IsZero() {
+				message.Time = event.Time
+			}
+			s.questions.NewMessage(message)
+		}
+	case eventlog.KindModeration:
+		if event.Command != nil {
+			return s.questions.Execute(*event.Command)
+		}
+	case eventlog.KindQuizNext:
+		if q, ok := s.quizzes[event.Quiz]; ok {
+			return q.Next(event.Time)
+		}
+	case eventlog.KindPollState:
+		if pollCounter, ok := s.pollCounters[event.Poll]; ok {
+			return pollCounter.SetState(event.PollState, event.Time)
+		}
+	case eventlog.KindTranscription:
+		s.transcription.NewTranscriptionText(event.Text)
+	case eventlog.KindReset:
+		for _, pollCounter := range s.pollCounters {
+			pollCounter.Reset()
+		}
+		for _, q := range s.quizzes {
+			q.Reset()
+		}
+		s.questions.Reset()
+	}
+
+	return nil
+}
+
+// Applies the event, then logs it if it applied.
+func (s *eventState) record(event eventlog.Event) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if err := s.apply(event); err != nil {
+		return err
+	}
+	if s.eventLog != nil {
+		if err := s.eventLog.Append(event); err != nil {
+			log.Printf("error appending %s event (%v)", event.Kind, err)
+		}
+	}
+
+	return nil
+}
+
+// Routes chat to polls, quizzes and questions.
+func (s *eventState) newMessage(message chat.Message) {
+	log.Printf("Received chat message - %s", message)
+	_ = s.record(eventlog.ChatEvent(message))
+}
+
+// Returns the number of events replayed.
+func (s *eventState) replay() (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.eventLog.Replay(func(event eventlog.Event) {
+		_ = s.apply(event)
+	})
+}
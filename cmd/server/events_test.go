@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"presentation-service/internal/chat"
+	"presentation-service/internal/chat/counter"
+	"presentation-service/internal/chat/moderation"
+	"presentation-service/internal/chat/quiz"
+	"presentation-service/internal/eventlog"
+	"presentation-service/internal/notification"
+	"presentation-service/internal/token"
+	"presentation-service/internal/transcription"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func newTestEventState(t *testing.T, eventLog *eventlog.Log) *eventState {
+	t.Helper()
+	poll := counter.NewSendersByTokenActor(
+		"language-poll", 2, 0, counter.Counting{}, counter.Lifecycle{}, nil, counter.Voting{},
+		token.NewVocabularyExtractor(map[string]string{"go": "Go", "rust": "Rust"}), 0,
+	)
+	questions := moderation.NewMessageRouter(
+		"question", moderation.AttributionNames, []moderation.Consumer{poll}, chat.NewBroadcaster("rejected"), 10,
+	)
+
+	return &eventState{
+		pollCounters:  map[string]*counter.SendersByTokenCounter{"language-poll": poll},
+		quizzes:       map[string]*quiz.Quiz{},
+		questions:     questions,
+		transcription: transcription.NewBroadcaster(),
+		eventLog:      eventLog,
+	}
+}
+
+func current[T any](t *testing.T, subscribe func(
+	ctx context.Context, policy notification.DeliveryPolicy, afterSeq uint64,
+) (<-chan notification.Sequenced[T], error)) T {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	values, err := subscribe(ctx, notification.CoalesceLatest(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case value := <-values:
+		return value.Value
+	case <-time.After(time.Second):
+		t.Fatal("current value not sent")
+	}
+
+	return *new(T)
+}
+
+// In UTC, without monotonic clock readings, as logged.
+func questionsAt(messages moderation.Messages) moderation.Messages {
+	for i := range messages.Questions {
+		messages.Questions[i].Time = messages.Questions[i].Time.UTC()
+	}
+
+	return messages
+}
+
+func TestReplayReproducesLiveState(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	eventLog, err := eventlog.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = eventLog.Close() }()
+	live := newTestEventState(t, eventLog)
+
+	live.newMessage(chat.Message{Sender: "Jane", Recipient: "Everyone", Text: "go"})
+	live.newMessage(chat.Message{Sender: "Jane", Recipient: "Everyone", Text: "Why not Java?"})
+	_ = live.record(eventlog.ResetEvent())
+	live.newMessage(chat.Message{Sender: "Bob", Recipient: "Everyone", Text: "rust and go"})
+	live.newMessage(chat.Message{Sender: "Ann", Recipient: "Everyone", Text: "go"})
+	live.newMessage(chat.Message{Sender: "Ann", Recipient: "Everyone", Text: "What is a goroutine?"})
+	live.newMessage(chat.Message{Sender: "Bob", Recipient: "Everyone", Text: "Is it fast?"})
+	if err = live.record(eventlog.ModerationEvent(moderation.Command{Action: moderation.ActionApprove, ID: 2})); err != nil {
+		t.Fatal(err)
+	}
+	if err = live.record(eventlog.PollStateEvent("language-poll", counter.StateClosed)); err != nil {
+		t.Fatal(err)
+	}
+	live.newMessage(chat.Message{Sender: "Cam", Recipient: "Everyone", Text: "rust"})
+	_ = live.record(eventlog.TranscriptionEvent("Welcome"))
+	// Not logged, since it didn't apply
+	if err = live.record(eventlog.ModerationEvent(moderation.Command{Action: moderation.ActionApprove, ID: 9})); err == nil {
+		t.Error("approved a question that doesn't exist")
+	}
+
+	replayed := newTestEventState(t, eventLog)
+	numEvents, err := replayed.replay()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if numEvents != 11 {
+		t.Errorf("replayed %d events, want 11", numEvents)
+	}
+
+	liveQuestions := questionsAt(current(t, live.questions.SubscribeModerator))
+	if len(liveQuestions.Questions) != 3 {
+		t.Errorf("%d live questions, want the questions after the reset and the late vote", len(liveQuestions.Questions))
+	}
+	if replayedQuestions := questionsAt(current(t, replayed.questions.SubscribeModerator)); !reflect.DeepEqual(replayedQuestions, liveQuestions) {
+		t.Errorf("replayed questions %+v, want %+v", replayedQuestions, liveQuestions)
+	}
+	liveCounts := current(t, live.pollCounters["language-poll"].Subscribe)
+	if liveCounts.Votes != 3 || liveCounts.State != counter.StateClosed {
+		t.Errorf("live counts %+v, want 3 votes since the reset, closed", liveCounts)
+	}
+	if replayedCounts := current(t, replayed.pollCounters["language-poll"].Subscribe); !reflect.DeepEqual(replayedCounts, liveCounts) {
+		t.Errorf("replayed counts %+v, want %+v", replayedCounts, liveCounts)
+	}
+	liveTranscript := current(t, live.transcription.Subscribe)
+	if replayedTranscript := current(t, replayed.transcription.Subscribe); replayedTranscript != liveTranscript {
+		t.Errorf("replayed transcript %+v, want %+v", replayedTranscript, liveTranscript)
+	}
+}
@@ -1,7 +1,11 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"embed"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"github.com/gin-gonic/gin"
@@ -11,25 +15,47 @@ import (
 	"math"
 	"net/http"
 	"os"
+	"os/signal"
+	"presentation-service/internal/auth"
 	"presentation-service/internal/chat"
 	"presentation-service/internal/chat/counter"
+	"presentation-service/internal/chat/irc"
 	"presentation-service/internal/chat/moderation"
+	"presentation-service/internal/chat/quiz"
+	"presentation-service/internal/config"
+	"presentation-service/internal/eventlog"
+	"presentation-service/internal/notification"
 	"presentation-service/internal/token"
 	"presentation-service/internal/transcription"
 	"strings"
+	"syscall"
+	"time"
 )
 
 type cliParams struct {
-	htmlPath string
-	port     uint16
+	htmlPath       string
+	port           uint16
+	eventLogPath   string
+	configPath     string
+	vocabularyPath string
+	pingInterval   time.Duration
+	writeTimeout   time.Duration
+	allowedOrigins []string
 }
 
 func parseFlags() cliParams {
 	params := cliParams{}
 	var port uint
+	var allowedOrigins string
 
 	flag.StringVar(&params.htmlPath, "html-path", "", "Presentation HTML file path")
 	flag.UintVar(&port, "port", 8973, "HTTP server port")
+	flag.StringVar(&params.eventLogPath, "event-log-path", "", "Event log file path, replayed on startup (optional)")
+	flag.StringVar(&params.configPath, "config-path", "", "Poll configuration JSON file path (optional)")
+	flag.StringVar(&params.vocabularyPath, "vocabulary-path", "", "Token vocabulary JSON files directory, reloaded on SIGHUP (optional)")
+	flag.DurationVar(&params.pingInterval, "ping-interval", 30*time.Second, "Event stream keepalive interval, or 0 to disable")
+	flag.DurationVar(&params.writeTimeout, "write-timeout", 10*time.Second, "Event stream write timeout, or 0 to disable")
+	flag.StringVar(&allowedOrigins, "allowed-origins", "", "Comma-separated origins allowed besides the deck's own, or * for any (optional)")
 	flag.Parse()
 
 	// Required args
@@ -38,233 +64,421 @@ func parseFlags() cliParams {
 		os.Exit(1)
 	}
 	params.port = uint16(port)
+	for _, origin := range strings.Split(allowedOrigins, ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			params.allowedOrigins = append(params.allowedOrigins, origin)
+		}
+	}
 
 	return params
 }
 
-const routeSeparator = " to "
+// Not found without --vocabulary-path.
+func reloadVocabularies(vocabularies *token.VocabularyRegistry) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if vocabularies == nil {
+			c.Status(http.StatusNotFound)
+			return
+		}
+		if err := vocabularies.Reload(); err != nil {
+			c.String(http.StatusUnprocessableEntity, err.Error())
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
+
+func receiveChat(events *eventState, source chat.Source) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		messages, err := source.Messages(c.Request, time.Now())
+		var challenge *chat.Challenge
+		if errors.As(err, &challenge) {
+			c.String(http.StatusOK, challenge.Response)
+			return
+		}
+		if errors.Is(err, chat.ErrUnverifiedPayload) {
+			log.Printf("error receiving chat (%v)", err)
+			c.Status(http.StatusUnauthorized)
+			return
+		}
+		if err != nil {
+			log.Printf("error receiving chat (%v)", err)
+			c.Status(http.StatusBadRequest)
+			return
+		}
 
-var validRecipients = map[string]string{
-	"Everyone":             "Everyone",
-	"You":                  "You",
-	"You (Direct Message)": "You",
+		for _, message := range messages {
+			events.newMessage(message)
+		}
+		if replying, ok := source.(chat.ReplyingSource); ok {
+			c.JSON(http.StatusOK, replying.Reply(messages))
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
 }
 
-//go:embed public/html
-var fs embed.FS
+func receiveChatFrom(events *eventState, chatSources map[string]chat.Source) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		source, ok := chatSources[c.Param("source")]
+		if !ok {
+			c.Status(http.StatusNotFound)
+			return
+		}
+		receiveChat(events, source)(c)
+	}
+}
 
-func clientCloseListener(conn *websocket.Conn) <-chan struct{} {
-	closed := make(chan struct{})
-	go func() {
-		for {
-			_, _, readErr := conn.NextReader()
-			if readErr != nil {
-				if _, ok := readErr.(*websocket.CloseError); ok {
-					log.Printf("connection closed by client: %v", readErr)
-				} else {
-					log.Printf("unexpected websocket error: %v", readErr)
-				}
-				closed <- struct{}{}
-				close(closed)
-				break
-			}
+// Sources verifying signatures authenticate requests themselves, so webhook
+// URLs needn't carry a token. Other sources' requests need one of roles.
+func chatSourceRoles(chatSources map[string]chat.Source, roles gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if source, ok := chatSources[c.Param("source")].(chat.VerifyingSource); ok && source.Verifies() {
+			c.Next()
+			return
 		}
-	}()
+		roles(c)
+	}
+}
 
-	return closed
+func resetEvents(events *eventState) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		_ = events.record(eventlog.ResetEvent())
+		c.Status(http.StatusNoContent)
+	}
 }
 
+func pollHistory(pollCounters map[string]*counter.SendersByTokenCounter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		pollCounter, ok := pollCounters[c.Param("name")]
+		if !ok {
+			c.Status(http.StatusNotFound)
+			return
+		}
+		c.JSON(http.StatusOK, pollCounter.History())
+	}
+}
+
+//go:embed public/html
+var fs embed.FS
+
 func main() {
 	params := parseFlags()
+	cfg := config.Default()
+	if params.configPath != "" {
+		var err error
+		cfg, err = config.Load(params.configPath)
+		if err != nil {
+			log.Fatalf("failed to load config (%v)", err)
+		}
+	}
 
 	log.SetPrefix("[service] ")
-	wsupgrader := websocket.Upgrader{
-		ReadBufferSize:  1024,
-		WriteBufferSize: 1024,
-		CheckOrigin:     func(r *http.Request) bool { return true },
+	origins, err := auth.NewOriginPolicy(params.allowedOrigins)
+	if err != nil {
+		log.Fatalf("invalid allowed origins (%v)", err)
+	}
+	streams := &streamer{
+		wsupgrader: websocket.Upgrader{
+			ReadBufferSize:  1024,
+			WriteBufferSize: 1024,
+			CheckOrigin:     origins.CheckOrigin,
+		},
+		pingInterval: params.pingInterval,
+		writeTimeout: params.writeTimeout,
 	}
 
 	gin.SetMode(gin.ReleaseMode)
-	r := gin.Default()
+	r := gin.New()
+	r.Use(auth.StripQueryToken(), gin.Logger(), gin.Recovery())
 	r.SetHTMLTemplate(
 		template.Must(template.New("").ParseFS(fs, "public/html/*.html")),
 	)
+	sessionTTL, err := cfg.Auth.SessionDuration()
+	if err != nil {
+		log.Fatalf("invalid auth config (%v)", err)
+	}
+	authenticator, err := auth.NewAuthenticator(cfg.Auth.Tokens, cfg.Auth.SessionSecret, sessionTTL)
+	if err != nil {
+		log.Fatalf("failed to initialize authentication (%v)", err)
+	}
+	if len(cfg.Auth.Tokens) == 0 {
+		log.Println("WARNING: no auth tokens configured, every client is an admin")
+	}
+	r.Use(authenticator.Middleware())
+	presenter := auth.Require(auth.RolePresenter)
+	moderator := auth.Require(auth.RoleModerator)
+	transcriber := auth.Require(auth.RoleTranscriber)
+	admin := auth.Require(auth.RoleAdmin)
+	// Every state-changing route checks the origin, so that other sites can't
+	// submit forms to it - even with every client an admin
+	cors := origins.CORS()
 
-	chatMessageBroadcaster := chat.NewBroadcaster("chat")
 	rejectedMessageBroadcaster := chat.NewBroadcaster("rejected")
-	languagePollCounter := counter.NewSendersByTokenActor(
-		"language-poll", 3,
-		token.ExtractLanguages,
-		chatMessageBroadcaster, rejectedMessageBroadcaster, 200,
-	)
+	var vocabularies *token.VocabularyRegistry
+	if params.vocabularyPath != "" {
+		var err error
+		vocabularies, err = token.NewVocabularyRegistry(params.vocabularyPath)
+		if err != nil {
+			log.Fatalf("failed to load vocabularies (%v)", err)
+		}
+		reloadSignals := make(chan os.Signal, 1)
+		signal.Notify(reloadSignals, syscall.SIGHUP)
+		go func() {
+			for range reloadSignals {
+				if reloadErr := vocabularies.Reload(); reloadErr != nil {
+					log.Printf("failed to reload vocabularies (%v)", reloadErr)
+				}
+			}
+		}()
+	}
+
+	fuzzyMatchBroadcaster := moderation.NewFuzzyMatchBroadcaster()
+	pollCounters := make(map[string]*counter.SendersByTokenCounter, len(cfg.Polls))
+	consumers := make([]moderation.Consumer, 0, len(cfg.Polls)+len(cfg.Quizzes))
+	for _, poll := range cfg.Polls {
+		extractTokens, err := poll.ExtractTokens(vocabularies, fuzzyMatchBroadcaster.NewFuzzyMatch)
+		if err != nil {
+			log.Fatalf("failed to configure poll (%v)", err)
+		}
+		counting, err := poll.Counting()
+		if err != nil {
+			log.Fatalf("failed to configure poll (%v)", err)
+		}
+		pollCounters[poll.Name] = counter.NewSendersByTokenActor(
+			poll.Name, poll.TokensPerSender, poll.TopN, counting, poll.Lifecycle(),
+			poll.RatingScale(), poll.VotingOptions(), extractTokens, poll.InitialCapacity,
+		)
+		go pollCounters[poll.Name].Run(context.Background())
+		consumers = append(consumers, pollCounters[poll.Name])
+	}
+	quizzes := make(map[string]*quiz.Quiz, len(cfg.Quizzes))
+	for _, quizCfg := range cfg.Quizzes {
+		extractTokens, err := quizCfg.ExtractTokens(vocabularies)
+		if err != nil {
+			log.Fatalf("failed to configure quiz (%v)", err)
+		}
+		questions, err := quizCfg.QuizQuestions()
+		if err != nil {
+			log.Fatalf("failed to configure quiz (%v)", err)
+		}
+		quizzes[quizCfg.Name] = quiz.NewQuiz(quizCfg.Name, questions, quizCfg.LeaderboardSize, extractTokens)
+		consumers = append(consumers, quizzes[quizCfg.Name])
+	}
 	questionBroadcaster := moderation.NewMessageRouter(
-		"question", chatMessageBroadcaster, rejectedMessageBroadcaster, 10,
+		"question", cfg.Questions.Attribution, consumers,
+		rejectedMessageBroadcaster, 10,
 	)
 	transcriptionBroadcaster := transcription.NewBroadcaster()
+	events := &eventState{
+		pollCounters:  pollCounters,
+		quizzes:       quizzes,
+		questions:     questionBroadcaster,
+		transcription: transcriptionBroadcaster,
+	}
+
+	if params.eventLogPath != "" {
+		eventLog, err := eventlog.Open(params.eventLogPath)
+		if err != nil {
+			log.Fatalf("failed to open event log %s (%v)", params.eventLogPath, err)
+		}
+		defer func() { _ = eventLog.Close() }()
+		events.eventLog = eventLog
+
+		numEvents, err := events.replay()
+		if err != nil {
+			log.Fatalf("failed to replay event log %s (%v)", params.eventLogPath, err)
+		}
+		log.Printf("Replayed %d events from %s", numEvents, params.eventLogPath)
+
+		err = eventLog.Record(context.Background(), eventlog.KindRejected, rejectedMessageBroadcaster)
+		if err != nil {
+			log.Fatalf("failed to record rejected messages (%v)", err)
+		}
+	}
+	if cfg.IRC != nil {
+		go irc.NewClient(cfg.IRC.Options(), events.newMessage).Run(context.Background())
+	}
+
+	r.POST("/login", cors, authenticator.Login)
+	r.POST("/logout", cors, authenticator.Logout)
 
 	// Deck
 	r.GET("/", func(c *gin.Context) {
 		c.File(params.htmlPath)
 	})
 
-	r.GET("/event/language-poll", func(c *gin.Context) {
-		conn, err := wsupgrader.Upgrade(c.Writer, c.Request, nil)
-		if err != nil {
-			log.Printf("failed to upgrade websocket request %v", err)
+	r.GET("/event/poll/:name", func(c *gin.Context) {
+		pollCounter, ok := pollCounters[c.Param("name")]
+		if !ok {
+			c.Status(http.StatusNotFound)
 			return
 		}
-		defer func() { _ = conn.Close() }()
-		clientClosed := clientCloseListener(conn)
-
-		counts := make(chan counter.Counts)
-		languagePollCounter.Subscribe(counts)
-		defer languagePollCounter.Unsubscribe(counts)
-	poll:
-		for {
-			select {
-			case count := <-counts:
-				writeErr := conn.WriteJSON(count)
-				if writeErr != nil {
-					log.Printf("error sending poll response (%v)", writeErr)
-					break poll
-				}
-			case <-clientClosed:
-				break poll
+		// Replays history over the given duration (e.g. "?replay=10s"), instead of live results
+		if replay := c.Query("replay"); replay != "" {
+			duration, err := time.ParseDuration(replay)
+			if err != nil || duration < 0 {
+				c.Status(http.StatusBadRequest)
+				return
 			}
+			streamJSON(streams, c, "poll replay", func(ctx context.Context, _ uint64) (<-chan counter.Counts, error) {
+				return pollCounter.Replay(ctx, duration), nil
+			}, nil)
+			return
 		}
+		streamJSON(streams, c, "poll response", func(
+			ctx context.Context, afterSeq uint64,
+		) (<-chan notification.Sequenced[counter.Counts], error) {
+			return pollCounter.Subscribe(ctx, notification.CoalesceLatest(), afterSeq)
+		}, nil)
 	})
 
-	r.GET("/event/question", func(c *gin.Context) {
-		conn, err := wsupgrader.Upgrade(c.Writer, c.Request, nil)
-		if err != nil {
-			log.Printf("failed to upgrade websocket request %v", err)
+	r.GET("/poll/:name/history", pollHistory(pollCounters))
+
+	if languagePollCounter, ok := pollCounters["language-poll"]; ok {
+		r.GET("/event/language-poll", func(c *gin.Context) {
+			streamJSON(streams, c, "poll response", func(
+				ctx context.Context, afterSeq uint64,
+			) (<-chan notification.Sequenced[counter.Counts], error) {
+				return languagePollCounter.Subscribe(ctx, notification.CoalesceLatest(), afterSeq)
+			}, nil)
+		})
+	}
+
+	r.POST("/poll/:name/state", cors, presenter, func(c *gin.Context) {
+		name := c.Param("name")
+		if _, ok := pollCounters[name]; !ok {
+			c.Status(http.StatusNotFound)
 			return
 		}
-		defer func() { _ = conn.Close() }()
-		clientClosed := clientCloseListener(conn)
-
-		msgs := make(chan moderation.Messages)
-		questionBroadcaster.Subscribe(msgs)
-		defer questionBroadcaster.Unsubscribe(msgs)
-	poll:
-		for {
-			select {
-			case msg := <-msgs:
-				writeErr := conn.WriteJSON(msg)
-				if writeErr != nil {
-					log.Printf("error sending questions (%v)", writeErr)
-					break poll
-				}
-			case <-clientClosed:
-				break poll
-			}
+		state := counter.State(c.Query("state"))
+		if !state.Valid() {
+			c.Status(http.StatusBadRequest)
+			return
 		}
-	})
 
-	r.GET("/event/transcription", func(c *gin.Context) {
-		conn, err := wsupgrader.Upgrade(c.Writer, c.Request, nil)
-		if err != nil {
-			log.Printf("failed to upgrade websocket request %v", err)
+		if err := events.record(eventlog.PollStateEvent(name, state)); err != nil {
+			c.String(http.StatusConflict, err.Error())
 			return
 		}
-		defer func() { _ = conn.Close() }()
-		clientClosed := clientCloseListener(conn)
-
-		transcripts := make(chan transcription.Transcript)
-		transcriptionBroadcaster.Subscribe(transcripts)
-		defer transcriptionBroadcaster.Unsubscribe(transcripts)
-	poll:
-		for {
-			select {
-			case msg := <-transcripts:
-				writeErr := conn.WriteJSON(msg)
-				if writeErr != nil {
-					log.Printf("error sending transcription (%v)", writeErr)
-					break poll
-				}
-			case <-clientClosed:
-				break poll
-			}
-		}
+		c.Status(http.StatusNoContent)
 	})
 
-	// Moderation
-	r.GET("/moderator", func(c *gin.Context) {
-		c.HTML(http.StatusOK, "moderator.html", nil)
+	r.GET("/event/quiz/:name", func(c *gin.Context) {
+		q, ok := quizzes[c.Param("name")]
+		if !ok {
+			c.Status(http.StatusNotFound)
+			return
+		}
+		streamJSON(streams, c, "quiz leaderboard", func(
+			ctx context.Context, afterSeq uint64,
+		) (<-chan notification.Sequenced[quiz.Leaderboard], error) {
+			return q.Subscribe(ctx, notification.CoalesceLatest(), afterSeq)
+		}, nil)
 	})
 
-	r.GET("/moderator/event", func(c *gin.Context) {
-		conn, err := wsupgrader.Upgrade(c.Writer, c.Request, nil)
-		if err != nil {
-			log.Printf("failed to upgrade websocket request %v", err)
+	r.POST("/quiz/:name/next", cors, presenter, func(c *gin.Context) {
+		name := c.Param("name")
+		if _, ok := quizzes[name]; !ok {
+			c.Status(http.StatusNotFound)
 			return
 		}
-		defer func() { _ = conn.Close() }()
-		clientClosed := clientCloseListener(conn)
-
-		msgs := make(chan chat.Message)
-		rejectedMessageBroadcaster.Subscribe(msgs)
-		defer rejectedMessageBroadcaster.Unsubscribe(msgs)
-	poll:
-		for {
-			select {
-			case msg := <-msgs:
-				writeErr := conn.WriteJSON(msg)
-				if writeErr != nil {
-					log.Printf("error sending moderation chats (%v)", writeErr)
-					break poll
-				}
-			case <-clientClosed:
-				break poll
-			}
+
+		if err := events.record(eventlog.QuizNextEvent(name)); err != nil {
+			c.String(http.StatusConflict, err.Error())
+			return
 		}
+		c.Status(http.StatusNoContent)
 	})
 
-	r.POST("/chat", func(c *gin.Context) {
-		route := c.Query("route")
-		sepIdx := strings.LastIndex(route, routeSeparator)
-		if sepIdx == -1 {
-			log.Println("malformed chat route")
-			c.Status(http.StatusBadRequest)
+	r.GET("/event/question", func(c *gin.Context) {
+		streamJSON(streams, c, "questions", func(
+			ctx context.Context, afterSeq uint64,
+		) (<-chan notification.Sequenced[moderation.Messages], error) {
+			return questionBroadcaster.Subscribe(ctx, notification.CoalesceLatest(), afterSeq)
+		}, nil)
+	})
+
+	r.GET("/event/transcription", func(c *gin.Context) {
+		streamJSON(streams, c, "transcription", func(
+			ctx context.Context, afterSeq uint64,
+		) (<-chan notification.Sequenced[transcription.Transcript], error) {
+			return transcriptionBroadcaster.Subscribe(ctx, notification.CoalesceLatest(), afterSeq)
+		}, nil)
+	})
+
+	// Moderation
+	r.GET("/moderator", moderator, func(c *gin.Context) {
+		c.HTML(http.StatusOK, "moderator.html", nil)
+	})
+
+	executeModeration := func(data []byte) {
+		// Other frames are keepalives (the moderator page sends "40")
+		if trimmed := bytes.TrimSpace(data); len(trimmed) == 0 || trimmed[0] != '{' {
 			return
 		}
-
-		rawRecipient := route[sepIdx+len(routeSeparator):]
-		recipient, ok := validRecipients[rawRecipient]
-		if !ok {
-			log.Println("invalid chat recipient")
-			c.Status(http.StatusBadRequest)
+		var command moderation.Command
+		if err := json.Unmarshal(data, &command); err != nil {
+			log.Printf("malformed moderation command (%v)", err)
 			return
 		}
+		if err := events.record(eventlog.ModerationEvent(command)); err != nil {
+			log.Printf("error executing moderation command (%v)", err)
+		}
+	}
 
-		sender := route[:sepIdx]
+	r.GET("/moderator/event", moderator, func(c *gin.Context) {
+		streamJSON(streams, c, "moderation chats", func(
+			ctx context.Context, afterSeq uint64,
+		) (<-chan notification.Sequenced[moderation.RejectedMessage], error) {
+			return questionBroadcaster.SubscribeRejected(ctx, notification.DisconnectSlow(64, 5*time.Second), afterSeq)
+		}, executeModeration)
+	})
 
-		chatMessageBroadcaster.NewMessage(chat.Message{
-			Sender:    sender,
-			Recipient: recipient,
-			Text:      c.Query("text"),
-		})
-		c.Status(http.StatusNoContent)
+	r.GET("/moderator/event/question", moderator, func(c *gin.Context) {
+		streamJSON(streams, c, "moderated questions", func(
+			ctx context.Context, afterSeq uint64,
+		) (<-chan notification.Sequenced[moderation.Messages], error) {
+			return questionBroadcaster.SubscribeModerator(ctx, notification.CoalesceLatest(), afterSeq)
+		}, executeModeration)
 	})
 
-	r.GET("/reset", func(c *gin.Context) {
-		languagePollCounter.Reset()
-		questionBroadcaster.Reset()
-		c.Status(http.StatusNoContent)
+	r.GET("/moderator/event/fuzzy-match", moderator, func(c *gin.Context) {
+		streamJSON(streams, c, "fuzzy matches", func(
+			ctx context.Context, afterSeq uint64,
+		) (<-chan notification.Sequenced[token.FuzzyMatch], error) {
+			return fuzzyMatchBroadcaster.Subscribe(ctx, notification.DisconnectSlow(64, 5*time.Second), afterSeq)
+		}, nil)
 	})
 
+	r.OPTIONS("/chat", cors)
+	chatRoles := auth.Require(auth.RoleModerator, auth.RolePresenter)
+	r.POST("/chat", cors, chatRoles, receiveChat(events, chat.ZoomSource{}))
+	chatSources, err := cfg.Chat.SourcesByName()
+	if err != nil {
+		log.Fatalf("invalid chat config (%v)", err)
+	}
+	r.OPTIONS("/chat/:source", cors)
+	r.POST("/chat/:source", cors, chatSourceRoles(chatSources, chatRoles), receiveChatFrom(events, chatSources))
+
+	// Admin
+	r.POST("/admin/vocabulary/reload", cors, admin, reloadVocabularies(vocabularies))
+
+	r.POST("/reset", cors, presenter, resetEvents(events))
+
 	// Transcription
-	r.GET("/transcriber", func(c *gin.Context) {
+	r.GET("/transcriber", transcriber, func(c *gin.Context) {
 		c.HTML(http.StatusOK, "transcriber.html", nil)
 	})
 
-	r.POST("/transcription", func(c *gin.Context) {
-		transcriptionBroadcaster.NewTranscriptionText(c.Query("text"))
+	r.OPTIONS("/transcription", cors)
+	r.POST("/transcription", cors, transcriber, func(c *gin.Context) {
+		_ = events.record(eventlog.TranscriptionEvent(c.Query("text")))
 		c.Status(http.StatusNoContent)
 	})
 
 	_ = r.SetTrustedProxies(nil)
 	serverAddr := fmt.Sprintf("0.0.0.0:%d", params.port)
 	log.Printf("Server starting on http://%s\n", serverAddr)
-	_ = r.Run(serverAddr)
+	_ = http.ListenAndServe(serverAddr, withResponseWriter(r))
 }
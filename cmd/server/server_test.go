@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"presentation-service/internal/auth"
+	"presentation-service/internal/chat"
+	"presentation-service/internal/chat/counter"
+	"presentation-service/internal/token"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestReloadVocabularies(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	dir := t.TempDir()
+	path := filepath.Join(dir, "editors.json")
+	write := func(content string) {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(`{"vim": "Vim"}`)
+	vocabularies, err := token.NewVocabularyRegistry(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	extract, _ := vocabularies.Extractor("editors", nil, nil)
+	reload := func(vocabularies *token.VocabularyRegistry) int {
+		r := gin.New()
+		r.POST("/admin/vocabulary/reload", reloadVocabularies(vocabularies))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/admin/vocabulary/reload", nil))
+
+		return w.Code
+	}
+
+	write(`{"vim": "Vim", "emacs": "Emacs"}`)
+	if status := reload(vocabularies); status != http.StatusNoContent {
+		t.Errorf("status %d, want %d", status, http.StatusNoContent)
+	}
+	if got := extract("emacs"); !reflect.DeepEqual(got, []string{"Emacs"}) {
+		t.Errorf("extracted %v, want reloaded aliases", got)
+	}
+
+	write(`{"vim": "Vim", "emacs": "Emacs", "EMACS": "Spacemacs"}`)
+	if status := reload(vocabularies); status != http.StatusUnprocessableEntity {
+		t.Errorf("status %d, want %d for a duplicate alias", status, http.StatusUnprocessableEntity)
+	}
+	if got := extract("emacs"); !reflect.DeepEqual(got, []string{"Emacs"}) {
+		t.Errorf("extracted %v, want previous aliases retained", got)
+	}
+
+	if status := reload(nil); status != http.StatusNotFound {
+		t.Errorf("status %d, want %d without vocabularies", status, http.StatusNotFound)
+	}
+}
+
+func TestPollHistory(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	poll := counter.NewSendersByTokenActor(
+		"language-poll", 1, 0, counter.Counting{}, counter.Lifecycle{HideUntilReveal: true}, nil, counter.Voting{},
+		token.NewVocabularyExtractor(map[string]string{"go": "Go"}), 0,
+	)
+	poll.NewMessage(chat.Message{Sender: "Jane", Text: "go"})
+	if err := poll.SetState(counter.StateRevealed, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	r := gin.New()
+	r.GET("/poll/:name/history", pollHistory(map[string]*counter.SendersByTokenCounter{"language-poll": poll}))
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+
+		return w
+	}
+
+	deadline := time.Now().Add(time.Second)
+	var history []counter.Snapshot
+	for len(history) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		w := get("/poll/language-poll/history")
+		if w.Code != http.StatusOK {
+			t.Fatalf("status %d, want %d", w.Code, http.StatusOK)
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &history); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(history) == 0 {
+		t.Fatal("no history")
+	}
+	last := history[len(history)-1].Counts
+	if last.Hidden || last.Votes != 1 || len(last.Results) != 1 || last.Results[0].Token != "Go" {
+		t.Errorf("last snapshot %+v, want the revealed vote for Go", last)
+	}
+
+	if w := get("/poll/rating-poll/history"); w.Code != http.StatusNotFound {
+		t.Errorf("status %d, want %d for an unknown poll", w.Code, http.StatusNotFound)
+	}
+}
+
+func TestResetRejectsForeignOrigin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	events := newTestEventState(t, nil)
+	origins, err := auth.NewOriginPolicy(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Without tokens, every client is an admin
+	authenticator, err := auth.NewAuthenticator(nil, "", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := gin.New()
+	r.Use(authenticator.Middleware())
+	r.POST("/reset", origins.CORS(), auth.Require(auth.RolePresenter), resetEvents(events))
+	reset := func(origin string) int {
+		request := httptest.NewRequest(http.MethodPost, "http://deck.example.com/reset", nil)
+		request.Header.Set("Origin", origin)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, request)
+
+		return w.Code
+	}
+	events.newMessage(chat.Message{Sender: "Jane", Text: "go"})
+
+	if status := reset("https://evil.example.com"); status != http.StatusForbidden {
+		t.Errorf("status %d, want %d from a foreign origin", status, http.StatusForbidden)
+	}
+	if counts := current(t, events.pollCounters["language-poll"].Subscribe); counts.Votes != 1 {
+		t.Errorf("%d votes, want the foreign reset ignored", counts.Votes)
+	}
+
+	if status := reset("https://deck.example.com"); status != http.StatusNoContent {
+		t.Errorf("status %d, want %d from the deck", status, http.StatusNoContent)
+	}
+	if counts := current(t, events.pollCounters["language-poll"].Subscribe); counts.Votes != 0 {
+		t.Errorf("%d votes, want reset", counts.Votes)
+	}
+}
+
+func TestVerifiedChatSourcesNeedNoToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	body, err := os.ReadFile(filepath.Join("..", "..", "fixtures", "chat", "teams-message.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := []byte("teams outgoing webhook key")
+	teams, err := chat.NewTeamsSource(base64.StdEncoding.EncodeToString(key))
+	if err != nil {
+		t.Fatal(err)
+	}
+	unverifiedTeams, err := chat.NewTeamsSource("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	chatSources := map[string]chat.Source{"teams": teams, "unverified-teams": unverifiedTeams}
+	authenticator, err := auth.NewAuthenticator(map[auth.Role]string{auth.RoleModerator: "moderator-token"}, "", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := newTestEventState(t, nil)
+	r := gin.New()
+	r.Use(authenticator.Middleware())
+	r.POST(
+		"/chat/:source", chatSourceRoles(chatSources, auth.Require(auth.RoleModerator)),
+		receiveChatFrom(events, chatSources),
+	)
+	post := func(source, authorization string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodPost, "/chat/"+source, bytes.NewReader(body))
+		if authorization != "" {
+			request.Header.Set("Authorization", authorization)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, request)
+
+		return w
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write(body)
+	signature := "HMAC " + base64.StdEncoding.EncodeToString(mac.Sum(nil))
+
+	for _, test := range []struct {
+		name          string
+		source        string
+		authorization string
+		wantStatus    int
+	}{
+		{name: "signed", source: "teams", authorization: signature, wantStatus: http.StatusOK},
+		{name: "unsigned", source: "teams", wantStatus: http.StatusUnauthorized},
+		{name: "token without a signature", source: "teams", authorization: "Bearer moderator-token", wantStatus: http.StatusUnauthorized},
+		{name: "unverified source without a token", source: "unverified-teams", wantStatus: http.StatusUnauthorized},
+		{name: "unverified source with a token", source: "unverified-teams", authorization: "Bearer moderator-token", wantStatus: http.StatusOK},
+		{name: "unknown source", source: "irc", authorization: "Bearer moderator-token", wantStatus: http.StatusNotFound},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			w := post(test.source, test.authorization)
+			if w.Code != test.wantStatus {
+				t.Fatalf("status %d, want %d", w.Code, test.wantStatus)
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+			var reply struct {
+				Type string `json:"type"`
+				Text string `json:"text"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &reply); err != nil || reply.Type != "message" {
+				t.Errorf("reply %q, want a Teams message", w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"log"
+	"net/http"
+	"presentation-service/internal/notification"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type streamer struct {
+	wsupgrader websocket.Upgrader
+	// WebSocket clients are pinged, and SSE clients sent comments, every
+	// pingInterval. WebSocket clients are disconnected if they don't respond
+	// within another pingInterval.
+	pingInterval time.Duration
+	writeTimeout time.Duration
+}
+
+// Zero if there is no deadline.
+func deadline(timeout time.Duration) time.Time {
+	if timeout <= 0 {
+		return time.Time{}
+	}
+
+	return time.Now().Add(timeout)
+}
+
+// The returned context is cancelled when the client closes the connection, or
+// when nothing is received from it within idleTimeout, if it is positive.
+// Messages from the client are passed to onMessage, if it is not nil.
+func clientCloseContext(
+	parent context.Context, conn *websocket.Conn, idleTimeout time.Duration, onMessage func([]byte),
+) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(parent)
+	_ = conn.SetReadDeadline(deadline(idleTimeout))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(deadline(idleTimeout))
+	})
+	go func() {
+		defer cancel()
+		for {
+			_, data, readErr := conn.ReadMessage()
+			if readErr != nil {
+				if _, ok := readErr.(*websocket.CloseError); ok {
+					log.Printf("connection closed by client: %v", readErr)
+				} else {
+					log.Printf("unexpected websocket error: %v", readErr)
+				}
+				return
+			}
+			_ = conn.SetReadDeadline(deadline(idleTimeout))
+			if onMessage != nil {
+				onMessage(data)
+			}
+		}
+	}()
+
+	return ctx, cancel
+}
+
+// Values with sequence numbers may be resumed after the last value received.
+// Resets tell the client it missed values, and should refetch its state.
+type resumable interface {
+	SequenceNumber() uint64
+	IsReset() bool
+}
+
+// Sequence numbers restart with the server, so event IDs are prefixed with
+// when it started, e.g. "lq8k2x0-12".
+var epoch = strconv.FormatInt(time.Now().UnixMilli(), 36)
+
+func eventID(seq uint64) string {
+	return epoch + "-" + strconv.FormatUint(seq, 10)
+}
+
+// WebSocket clients opting in with ?sequenced=true receive values with their
+// sequence numbers and event IDs.
+type sequencedJSON struct {
+	Seq   uint64 `json:"seq"`
+	ID    string `json:"id"`
+	Value any    `json:"value,omitempty"`
+	Reset bool   `json:"reset,omitempty"`
+}
+
+// Clients resuming a stream send the last event ID they received, as
+// Last-Event-ID with SSE, or the lastEventId query parameter. IDs from before
+// the server restarted are unknown.
+func lastEventID(c *gin.Context) uint64 {
+	id := c.GetHeader("Last-Event-ID")
+	if id == "" {
+		id = c.Query("lastEventId")
+	}
+	if id == "" {
+		return 0
+	}
+	seq, err := strconv.ParseUint(strings.TrimPrefix(id, epoch+"-"), 10, 64)
+	if err != nil || !strings.HasPrefix(id, epoch+"-") {
+		return notification.UnknownSeq
+	}
+
+	return seq
+}
+
+type responseWriterKey struct{}
+
+// Gin's ResponseWriter can't be unwrapped by http.ResponseController, so the
+// server's is kept in the request context.
+func withResponseWriter(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), responseWriterKey{}, w)))
+	})
+}
+
+func responseController(c *gin.Context) *http.ResponseController {
+	if w, ok := c.Request.Context().Value(responseWriterKey{}).(http.ResponseWriter); ok {
+		return http.NewResponseController(w)
+	}
+
+	return http.NewResponseController(c.Writer)
+}
+
+// Streams over SSE if the client asks for it (as EventSource does), or with
+// ?transport=sse, and over WebSocket otherwise. Snapshot streams skip the
+// first snapshot if afterSeq is the latest.
+func streamJSON[T any](
+	s *streamer, c *gin.Context, name string,
+	subscribe func(ctx context.Context, afterSeq uint64) (<-chan T, error), onMessage func([]byte),
+) {
+	if c.Query("transport") == "sse" || strings.Contains(c.GetHeader("Accept"), "text/event-stream") {
+		streamSSE(s, c, name, subscribe)
+		return
+	}
+
+	conn, err := s.wsupgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		log.Printf("failed to upgrade websocket request %v", err)
+		return
+	}
+	defer func() { _ = conn.Close() }()
+	idleTimeout := time.Duration(0)
+	if s.pingInterval > 0 {
+		idleTimeout = 2 * s.pingInterval
+	}
+	ctx, cancel := clientCloseContext(c.Request.Context(), conn, idleTimeout, onMessage)
+	defer cancel()
+
+	values, err := subscribe(ctx, lastEventID(c))
+	if err != nil {
+		log.Printf("error subscribing to %s (%v)", name, err)
+		return
+	}
+	if s.pingInterval > 0 {
+		go func() {
+			ticker := time.NewTicker(s.pingInterval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+					if pingErr := conn.WriteControl(websocket.PingMessage, nil, deadline(s.writeTimeout)); pingErr != nil {
+						log.Printf("error pinging %s client (%v)", name, pingErr)
+						cancel()
+						return
+					}
+				}
+			}
+		}()
+	}
+	sequenced := c.Query("sequenced") == "true"
+	for value := range values {
+		var message any = value
+		if r, ok := any(value).(resumable); ok {
+			// Only sequenced clients can resume, so only they are sent resets
+			if !sequenced && r.IsReset() {
+				continue
+			}
+			if sequenced {
+				sequencedMessage := sequencedJSON{Seq: r.SequenceNumber(), ID: eventID(r.SequenceNumber()), Reset: r.IsReset()}
+				if !r.IsReset() {
+					sequencedMessage.Value = value
+				}
+				message = sequencedMessage
+			}
+		}
+		_ = conn.SetWriteDeadline(deadline(s.writeTimeout))
+		writeErr := conn.WriteJSON(message)
+		if writeErr != nil {
+			log.Printf("error sending %s (%v)", name, writeErr)
+			break
+		}
+	}
+}
+
+// SSE streams are one-way - clients send commands over WebSocket instead.
+func streamSSE[T any](
+	s *streamer, c *gin.Context, name string,
+	subscribe func(ctx context.Context, afterSeq uint64) (<-chan T, error),
+) {
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+	values, err := subscribe(ctx, lastEventID(c))
+	if err != nil {
+		log.Printf("error subscribing to %s (%v)", name, err)
+		c.Status(http.StatusServiceUnavailable)
+		return
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+	controller := responseController(c)
+	defer func() { _ = controller.SetWriteDeadline(time.Time{}) }()
+	var keepalive <-chan time.Time
+	if s.pingInterval > 0 {
+		ticker := time.NewTicker(s.pingInterval)
+		defer ticker.Stop()
+		keepalive = ticker.C
+	}
+	for {
+		var event strings.Builder
+		select {
+		case value, ok := <-values:
+			if !ok {
+				return
+			}
+			data, marshalErr := json.Marshal(value)
+			if marshalErr != nil {
+				log.Printf("error encoding %s (%v)", name, marshalErr)
+				return
+			}
+			if r, ok := any(value).(resumable); ok {
+				event.WriteString("id: " + eventID(r.SequenceNumber()) + "\n")
+				if r.IsReset() {
+					event.WriteString("event: reset\n")
+					data = []byte("{}")
+				}
+			}
+			event.WriteString("data: ")
+			event.Write(data)
+			event.WriteString("\n\n")
+		case <-keepalive:
+			event.WriteString(": keepalive\n\n")
+		}
+		_ = controller.SetWriteDeadline(deadline(s.writeTimeout))
+		if _, writeErr := c.Writer.WriteString(event.String()); writeErr != nil {
+			log.Printf("error sending %s (%v)", name, writeErr)
+			return
+		}
+		c.Writer.Flush()
+	}
+}
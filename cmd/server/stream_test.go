@@ -0,0 +1,301 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"presentation-service/internal/notification"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Serves the notification's events over SSE.
+func newSSEServer(
+	t *testing.T, n *notification.SequencedNotification[string], pingInterval time.Duration,
+) *httptest.Server {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	s := &streamer{pingInterval: pingInterval, writeTimeout: time.Second}
+	r.GET("/event", func(c *gin.Context) {
+		streamSSE(s, c, "test event", func(
+			ctx context.Context, afterSeq uint64,
+		) (<-chan notification.Sequenced[string], error) {
+			subscription, err := n.SubscribeAfter(ctx, notification.DropOldest(10), afterSeq)
+			if err != nil {
+				return nil, err
+			}
+
+			return subscription.Values(), nil
+		})
+	})
+	server := httptest.NewServer(withResponseWriter(r))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+// Returns a reader of the event stream, resuming after lastEventID if it is
+// not empty.
+func getSSE(t *testing.T, server *httptest.Server, lastEventID string) *bufio.Reader {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/event", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	request.Header.Set("Accept", "text/event-stream")
+	if lastEventID != "" {
+		request.Header.Set("Last-Event-ID", lastEventID)
+	}
+	response, err := server.Client().Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = response.Body.Close() })
+	if contentType := response.Header.Get("Content-Type"); contentType != "text/event-stream" {
+		t.Fatalf("content type %q, want text/event-stream", contentType)
+	}
+
+	return bufio.NewReader(response.Body)
+}
+
+// Returns the lines of the next event, up to the blank line ending it.
+func readSSE(t *testing.T, events *bufio.Reader) string {
+	t.Helper()
+	var lines []string
+	for {
+		line, err := events.ReadString('\n')
+		if err != nil {
+			t.Fatalf("error reading event after %q (%v)", lines, err)
+		}
+		if line == "\n" {
+			return strings.Join(lines, "")
+		}
+		lines = append(lines, line)
+	}
+}
+
+func TestStreamSSEResumesAfterLastEventID(t *testing.T) {
+	n := notification.NewSequencedNotification[string](10)
+	for _, value := range []string{"a", "b", "c"} {
+		n.NotifyAll(value)
+	}
+	server := newSSEServer(t, n, 0)
+
+	events := getSSE(t, server, eventID(1))
+	for _, want := range []string{
+		"id: " + eventID(2) + "\ndata: \"b\"\n",
+		"id: " + eventID(3) + "\ndata: \"c\"\n",
+	} {
+		if event := readSSE(t, events); event != want {
+			t.Errorf("event %q, want %q", event, want)
+		}
+	}
+
+	// Live values follow
+	n.NotifyAll("d")
+	if event, want := readSSE(t, events), "id: "+eventID(4)+"\ndata: \"d\"\n"; event != want {
+		t.Errorf("event %q, want %q", event, want)
+	}
+}
+
+func TestStreamSSESendsResetForMissedEvents(t *testing.T) {
+	n := notification.NewSequencedNotification[string](1)
+	for _, value := range []string{"a", "b", "c"} {
+		n.NotifyAll(value)
+	}
+	server := newSSEServer(t, n, 0)
+
+	for _, test := range []struct {
+		name        string
+		lastEventID string
+		want        []string
+	}{
+		{
+			name:        "no longer kept",
+			lastEventID: eventID(1),
+			want: []string{
+				"id: " + eventID(2) + "\nevent: reset\ndata: {}\n",
+				"id: " + eventID(3) + "\ndata: \"c\"\n",
+			},
+		},
+		{
+			// Nothing can be resumed, so the client refetches its state
+			name:        "before a server restart",
+			lastEventID: "lq8k2x0-2",
+			want:        []string{"id: " + eventID(3) + "\nevent: reset\ndata: {}\n"},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			events := getSSE(t, server, test.lastEventID)
+			for _, want := range test.want {
+				if event := readSSE(t, events); event != want {
+					t.Errorf("event %q, want %q", event, want)
+				}
+			}
+		})
+	}
+}
+
+func TestStreamSSESendsKeepalive(t *testing.T) {
+	n := notification.NewSequencedNotification[string](1)
+	server := newSSEServer(t, n, 10*time.Millisecond)
+
+	events := getSSE(t, server, "")
+	for i := 0; i < 2; i++ {
+		if event := readSSE(t, events); event != ": keepalive\n" {
+			t.Errorf("event %q, want a keepalive comment", event)
+		}
+	}
+}
+
+// Serves the notification's events over WebSocket.
+func newWebSocketServer(
+	t *testing.T, n *notification.SequencedNotification[string], pingInterval time.Duration,
+) *httptest.Server {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	s := &streamer{pingInterval: pingInterval, writeTimeout: time.Second}
+	r.GET("/event", func(c *gin.Context) {
+		streamJSON(s, c, "test event", func(
+			ctx context.Context, afterSeq uint64,
+		) (<-chan notification.Sequenced[string], error) {
+			subscription, err := n.SubscribeAfter(ctx, notification.DropOldest(10), afterSeq)
+			if err != nil {
+				return nil, err
+			}
+
+			return subscription.Values(), nil
+		}, nil)
+	})
+	server := httptest.NewServer(r)
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+// Connects to the event socket with the given query parameters.
+func dialWebSocket(t *testing.T, server *httptest.Server, query string) *websocket.Conn {
+	t.Helper()
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/event?" + query
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	return conn
+}
+
+func TestStreamJSONSendsSequencedValues(t *testing.T) {
+	n := notification.NewSequencedNotification[string](10)
+	for _, value := range []string{"a", "b", "c"} {
+		n.NotifyAll(value)
+	}
+	server := newWebSocketServer(t, n, 0)
+
+	conn := dialWebSocket(t, server, "sequenced=true&lastEventId="+eventID(1))
+	for _, want := range []sequencedJSON{
+		{Seq: 2, ID: eventID(2), Value: "b"},
+		{Seq: 3, ID: eventID(3), Value: "c"},
+	} {
+		var message sequencedJSON
+		if err := conn.ReadJSON(&message); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(message, want) {
+			t.Errorf("received %+v, want %+v", message, want)
+		}
+	}
+
+	// Live values follow
+	n.NotifyAll("d")
+	var message sequencedJSON
+	if err := conn.ReadJSON(&message); err != nil {
+		t.Fatal(err)
+	}
+	if want := (sequencedJSON{Seq: 4, ID: eventID(4), Value: "d"}); !reflect.DeepEqual(message, want) {
+		t.Errorf("received %+v, want %+v", message, want)
+	}
+}
+
+func TestStreamJSONSendsResetsOnlyToSequencedClients(t *testing.T) {
+	n := notification.NewSequencedNotification[string](1)
+	for _, value := range []string{"a", "b", "c"} {
+		n.NotifyAll(value)
+	}
+	server := newWebSocketServer(t, n, 0)
+
+	for _, test := range []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{
+			name:  "sequenced",
+			query: "sequenced=true&lastEventId=" + eventID(1),
+			want: []string{
+				`{"seq":2,"id":"` + eventID(2) + `","reset":true}`,
+				`{"seq":3,"id":"` + eventID(3) + `","value":"c"}`,
+			},
+		},
+		{
+			name:  "unsequenced",
+			query: "lastEventId=" + eventID(1),
+			want:  []string{`"c"`},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			conn := dialWebSocket(t, server, test.query)
+			for _, want := range test.want {
+				_, data, err := conn.ReadMessage()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if message := strings.TrimSpace(string(data)); message != want {
+					t.Errorf("received %s, want %s", message, want)
+				}
+			}
+		})
+	}
+}
+
+func TestStreamJSONClosesWhenPongsStop(t *testing.T) {
+	n := notification.NewSequencedNotification[string](1)
+	server := newWebSocketServer(t, n, 20*time.Millisecond)
+
+	// Answering pings keeps the connection open past the idle timeout
+	conn := dialWebSocket(t, server, "")
+	_ = conn.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+	var netErr net.Error
+	if _, _, err := conn.ReadMessage(); !errors.As(err, &netErr) || !netErr.Timeout() {
+		t.Errorf("read returned %v, want the connection kept open", err)
+	}
+
+	conn = dialWebSocket(t, server, "")
+	conn.SetPingHandler(func(string) error { return nil })
+	closed := make(chan error, 1)
+	go func() {
+		_, _, err := conn.ReadMessage()
+		closed <- err
+	}()
+	select {
+	case err := <-closed:
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			t.Errorf("read timed out, want the connection closed by the server")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("connection still open, want it closed without pongs")
+	}
+}
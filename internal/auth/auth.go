@@ -0,0 +1,163 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"github.com/gin-gonic/gin"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	SessionCookieName    = "session"
+	roleContextKey       = "auth.role"
+	queryTokenContextKey = "auth.queryToken"
+)
+
+type Authenticator struct {
+	tokensByRole map[Role]string
+	sessions     sessions
+}
+
+func (a *Authenticator) enabled() bool {
+	return len(a.tokensByRole) > 0
+}
+
+func (a *Authenticator) roleForToken(token string) (Role, bool) {
+	for role, roleToken := range a.tokensByRole {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(roleToken)) == 1 {
+			return role, true
+		}
+	}
+
+	return "", false
+}
+
+func (a *Authenticator) setSessionCookie(c *gin.Context, role Role) {
+	c.SetSameSite(http.SameSiteStrictMode)
+	c.SetCookie(
+		SessionCookieName, a.sessions.issue(role, time.Now()), int(a.sessions.ttl.Seconds()),
+		"/", "", c.Request.TLS != nil, true,
+	)
+}
+
+// Takes the token query parameter out of the request URL for Middleware, so
+// that it isn't logged. Must be used ahead of the logger.
+func StripQueryToken() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		query := c.Request.URL.Query()
+		if _, ok := query["token"]; ok {
+			c.Set(queryTokenContextKey, query.Get("token"))
+			query.Del("token")
+			c.Request.URL.RawQuery = query.Encode()
+		}
+		c.Next()
+	}
+}
+
+// Resolves the client's role, from a bearer token, a token query parameter
+// (see StripQueryToken), or a session cookie. Clients without valid
+// credentials are in the audience. A valid token query parameter also starts a
+// session, for pages and their event streams, and pages are redirected to
+// their URL without it.
+func (a *Authenticator) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role := RoleAudience
+		if !a.enabled() {
+			role = RoleAdmin
+		} else if bearer := c.GetHeader("Authorization"); strings.HasPrefix(bearer, "Bearer ") {
+			if tokenRole, ok := a.roleForToken(strings.TrimPrefix(bearer, "Bearer ")); ok {
+				role = tokenRole
+			}
+		} else if token := c.GetString(queryTokenContextKey); token != "" {
+			if tokenRole, ok := a.roleForToken(token); ok {
+				role = tokenRole
+				a.setSessionCookie(c, role)
+				if c.Request.Method == http.MethodGet && strings.Contains(c.GetHeader("Accept"), "text/html") {
+					c.Redirect(http.StatusSeeOther, c.Request.URL.RequestURI())
+					c.Abort()
+					return
+				}
+			}
+		} else if cookie, err := c.Cookie(SessionCookieName); err == nil {
+			if sessionRole, ok := a.sessions.verify(cookie, time.Now()); ok {
+				role = sessionRole
+			}
+		}
+		c.Set(roleContextKey, role)
+		c.Next()
+	}
+}
+
+func RoleOf(c *gin.Context) Role {
+	if role, ok := c.Get(roleContextKey); ok {
+		return role.(Role)
+	}
+
+	return RoleAudience
+}
+
+// Admits clients in any of roles, responding 401 to the audience, and 403 to
+// clients in other roles.
+func Require(roles ...Role) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		clientRole := RoleOf(c)
+		for _, role := range roles {
+			if clientRole.allows(role) {
+				c.Next()
+				return
+			}
+		}
+		if clientRole == RoleAudience {
+			c.AbortWithStatus(http.StatusUnauthorized)
+		} else {
+			c.AbortWithStatus(http.StatusForbidden)
+		}
+	}
+}
+
+// Exchanges a token (form or JSON "token" field) for a session cookie.
+func (a *Authenticator) Login(c *gin.Context) {
+	var credentials struct {
+		Token string `json:"token" form:"token"`
+	}
+	if err := c.ShouldBind(&credentials); err != nil || credentials.Token == "" {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+	role, ok := a.roleForToken(credentials.Token)
+	if !ok {
+		log.Printf("Rejected login from %s", c.ClientIP())
+		c.Status(http.StatusUnauthorized)
+		return
+	}
+	a.setSessionCookie(c, role)
+	c.JSON(http.StatusOK, gin.H{"role": role})
+}
+
+func (a *Authenticator) Logout(c *gin.Context) {
+	c.SetSameSite(http.SameSiteStrictMode)
+	c.SetCookie(SessionCookieName, "", -1, "/", "", c.Request.TLS != nil, true)
+	c.Status(http.StatusNoContent)
+}
+
+// Authentication is disabled, with every client an admin, if tokensByRole is
+// empty. Sessions are signed with secret, or a random secret if it is empty,
+// in which case sessions end when the server restarts.
+func NewAuthenticator(
+	tokensByRole map[Role]string, secret string, sessionTTL time.Duration,
+) (*Authenticator, error) {
+	sessionSecret := []byte(secret)
+	if len(sessionSecret) == 0 {
+		var err error
+		if sessionSecret, err = randomSecret(); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Authenticator{
+		tokensByRole: tokensByRole,
+		sessions:     sessions{secret: sessionSecret, ttl: sessionTTL},
+	}, nil
+}
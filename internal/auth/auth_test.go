@@ -0,0 +1,108 @@
+package auth
+
+import (
+	"bytes"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const moderatorToken = "moderator-token-0123456789"
+
+func newTestEngine(t *testing.T, logged *bytes.Buffer) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	authenticator, err := NewAuthenticator(map[Role]string{RoleModerator: moderatorToken}, "secret", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := gin.New()
+	r.Use(StripQueryToken(), gin.LoggerWithWriter(logged), authenticator.Middleware())
+	r.GET("/moderator", Require(RoleModerator), func(c *gin.Context) {
+		c.String(http.StatusOK, c.Request.URL.RawQuery)
+	})
+	r.POST("/chat", Require(RoleModerator), func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+
+	return r
+}
+
+func TestQueryToken(t *testing.T) {
+	tests := []struct {
+		name         string
+		method       string
+		target       string
+		accept       string
+		wantStatus   int
+		wantLocation string
+		wantSession  bool
+	}{
+		{"page", http.MethodGet, "/moderator?token=" + moderatorToken + "&view=list", "text/html", http.StatusSeeOther, "/moderator?view=list", true},
+		{"event stream", http.MethodGet, "/moderator?token=" + moderatorToken, "text/event-stream", http.StatusOK, "", true},
+		{"webhook", http.MethodPost, "/chat?token=" + moderatorToken, "", http.StatusNoContent, "", true},
+		{"wrong token", http.MethodPost, "/chat?token=not-" + moderatorToken, "", http.StatusUnauthorized, "", false},
+		{"empty token", http.MethodPost, "/chat?token=", "", http.StatusUnauthorized, "", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var logged bytes.Buffer
+			r := newTestEngine(t, &logged)
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(test.method, test.target, nil)
+			req.Header.Set("Accept", test.accept)
+			r.ServeHTTP(w, req)
+
+			if w.Code != test.wantStatus {
+				t.Errorf("status %d, want %d", w.Code, test.wantStatus)
+			}
+			if location := w.Header().Get("Location"); location != test.wantLocation {
+				t.Errorf("location %q, want %q", location, test.wantLocation)
+			}
+			if session := strings.Contains(w.Header().Get("Set-Cookie"), SessionCookieName+"="); session != test.wantSession {
+				t.Errorf("session %t, want %t", session, test.wantSession)
+			}
+			if strings.Contains(logged.String(), "token") {
+				t.Errorf("logged %q, want token redacted", logged.String())
+			}
+			if strings.Contains(w.Body.String(), "token") {
+				t.Errorf("handler saw %q, want token removed", w.Body.String())
+			}
+		})
+	}
+}
+
+func TestSessionAfterRedirect(t *testing.T) {
+	var logged bytes.Buffer
+	r := newTestEngine(t, &logged)
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/moderator?token="+moderatorToken, nil)
+	req.Header.Set("Accept", "text/html")
+	r.ServeHTTP(w, req)
+
+	redirected := httptest.NewRequest(http.MethodGet, w.Header().Get("Location"), nil)
+	for _, cookie := range w.Result().Cookies() {
+		redirected.AddCookie(cookie)
+	}
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, redirected)
+	if w.Code != http.StatusOK {
+		t.Errorf("status %d, want %d", w.Code, http.StatusOK)
+	}
+}
+
+func TestBearerToken(t *testing.T) {
+	var logged bytes.Buffer
+	r := newTestEngine(t, &logged)
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/chat", nil)
+	req.Header.Set("Authorization", "Bearer "+moderatorToken)
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNoContent {
+		t.Errorf("status %d, want %d", w.Code, http.StatusNoContent)
+	}
+}
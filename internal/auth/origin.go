@@ -0,0 +1,109 @@
+package auth
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const corsMaxAgeSeconds = "600"
+
+// Browsers send an Origin with WebSocket upgrades and cross-origin requests.
+// Requests from the host serving the deck, and from clients that aren't
+// browsers (without an Origin), are always allowed.
+type OriginPolicy struct {
+	allowed  map[string]struct{}
+	allowAny bool
+}
+
+func normalizeOrigin(origin string) (string, error) {
+	parsed, err := url.Parse(origin)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" ||
+		(parsed.Path != "" && parsed.Path != "/") || parsed.RawQuery != "" || parsed.User != nil {
+		return "", fmt.Errorf(`invalid origin "%s", expected scheme://host[:port]`, origin)
+	}
+
+	return parsed.Scheme + "://" + strings.ToLower(parsed.Host), nil
+}
+
+func (p *OriginPolicy) Allowed(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" || p.allowAny {
+		return true
+	}
+	parsed, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	if strings.EqualFold(parsed.Host, r.Host) {
+		return true
+	}
+	normalized, err := normalizeOrigin(origin)
+	if err != nil {
+		return false
+	}
+	_, ok := p.allowed[normalized]
+
+	return ok
+}
+
+// For websocket.Upgrader.
+func (p *OriginPolicy) CheckOrigin(r *http.Request) bool {
+	if p.Allowed(r) {
+		return true
+	}
+	log.Printf("Rejected WebSocket upgrade from origin %s", r.Header.Get("Origin"))
+
+	return false
+}
+
+// Rejects requests from disallowed origins, and allows cross-origin requests
+// (including preflight OPTIONS requests) from allowed origins, with
+// credentials.
+func (p *OriginPolicy) CORS() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		if origin == "" {
+			c.Next()
+			return
+		}
+		if !p.Allowed(c.Request) {
+			log.Printf("Rejected %s %s from origin %s", c.Request.Method, c.Request.URL.Path, origin)
+			c.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+
+		c.Header("Access-Control-Allow-Origin", origin)
+		c.Header("Access-Control-Allow-Credentials", "true")
+		c.Header("Vary", "Origin")
+		if c.Request.Method == http.MethodOptions {
+			c.Header("Access-Control-Allow-Methods", "POST, OPTIONS")
+			c.Header("Access-Control-Allow-Headers", "Authorization, Content-Type")
+			c.Header("Access-Control-Max-Age", corsMaxAgeSeconds)
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+		c.Next()
+	}
+}
+
+// Origins are of the form scheme://host[:port], or "*" to allow any origin.
+func NewOriginPolicy(origins []string) (*OriginPolicy, error) {
+	policy := &OriginPolicy{allowed: make(map[string]struct{}, len(origins))}
+	for _, origin := range origins {
+		if origin == "*" {
+			policy.allowAny = true
+			continue
+		}
+		normalized, err := normalizeOrigin(origin)
+		if err != nil {
+			return nil, err
+		}
+		policy.allowed[normalized] = struct{}{}
+	}
+
+	return policy, nil
+}
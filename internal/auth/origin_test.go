@@ -0,0 +1,173 @@
+package auth
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const deckHost = "deck.example.com"
+
+func newTestOriginPolicy(t *testing.T, origins ...string) *OriginPolicy {
+	t.Helper()
+	policy, err := NewOriginPolicy(origins)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return policy
+}
+
+func TestOriginPolicyAllowed(t *testing.T) {
+	tests := []struct {
+		name    string
+		allowed []string
+		origin  string
+		want    bool
+	}{
+		{"no origin", nil, "", true},
+		{"same host", nil, "https://" + deckHost, true},
+		{"same host, different case", nil, "https://DECK.example.com", true},
+		{"allow-listed", []string{"https://relay.example.com"}, "https://relay.example.com", true},
+		{"allow-listed, different case", []string{"https://Relay.example.com/"}, "https://RELAY.example.com", true},
+		{"allow-listed, different scheme", []string{"https://relay.example.com"}, "http://relay.example.com", false},
+		{"allow-listed, different port", []string{"https://relay.example.com"}, "https://relay.example.com:8443", false},
+		{"unlisted", []string{"https://relay.example.com"}, "https://evil.example.com", false},
+		{"malformed", nil, "not an origin", false},
+		{"null", nil, "null", false},
+		{"any", []string{"*"}, "https://evil.example.com", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			policy := newTestOriginPolicy(t, test.allowed...)
+			r := httptest.NewRequest(http.MethodGet, "http://"+deckHost+"/event/question", nil)
+			if test.origin != "" {
+				r.Header.Set("Origin", test.origin)
+			}
+
+			if allowed := policy.Allowed(r); allowed != test.want {
+				t.Errorf("Allowed %t, want %t", allowed, test.want)
+			}
+			if allowed := policy.CheckOrigin(r); allowed != test.want {
+				t.Errorf("CheckOrigin %t, want %t", allowed, test.want)
+			}
+		})
+	}
+}
+
+func TestNewOriginPolicyRejectsInvalidOrigins(t *testing.T) {
+	for _, origin := range []string{
+		"relay.example.com", "ftp://relay.example.com", "https://", "https://relay.example.com/chat",
+		"https://relay.example.com?a=b", "https://user@relay.example.com",
+	} {
+		if _, err := NewOriginPolicy([]string{origin}); err == nil {
+			t.Errorf("accepted %s, want error", origin)
+		}
+	}
+}
+
+func TestCORS(t *testing.T) {
+	tests := []struct {
+		name       string
+		allowed    []string
+		method     string
+		origin     string
+		wantStatus int
+		wantACAO   string
+	}{
+		{"no origin", nil, http.MethodPost, "", http.StatusNoContent, ""},
+		{"same host", nil, http.MethodPost, "https://" + deckHost, http.StatusNoContent, "https://" + deckHost},
+		{"allow-listed", []string{"https://relay.example.com"}, http.MethodPost, "https://relay.example.com", http.StatusNoContent, "https://relay.example.com"},
+		{"unlisted", []string{"https://relay.example.com"}, http.MethodPost, "https://evil.example.com", http.StatusForbidden, ""},
+		{"any", []string{"*"}, http.MethodPost, "https://evil.example.com", http.StatusNoContent, "https://evil.example.com"},
+		{"preflight", []string{"https://relay.example.com"}, http.MethodOptions, "https://relay.example.com", http.StatusNoContent, "https://relay.example.com"},
+		{"unlisted preflight", []string{"https://relay.example.com"}, http.MethodOptions, "https://evil.example.com", http.StatusForbidden, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			cors := newTestOriginPolicy(t, test.allowed...).CORS()
+			handled := false
+			r := gin.New()
+			r.OPTIONS("/chat", cors)
+			r.POST("/chat", cors, func(c *gin.Context) {
+				handled = true
+				c.Status(http.StatusNoContent)
+			})
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(test.method, "http://"+deckHost+"/chat", nil)
+			if test.origin != "" {
+				req.Header.Set("Origin", test.origin)
+			}
+			r.ServeHTTP(w, req)
+
+			if w.Code != test.wantStatus {
+				t.Errorf("status %d, want %d", w.Code, test.wantStatus)
+			}
+			if acao := w.Header().Get("Access-Control-Allow-Origin"); acao != test.wantACAO {
+				t.Errorf("Access-Control-Allow-Origin %q, want %q", acao, test.wantACAO)
+			}
+			if test.wantACAO != "" {
+				if vary := w.Header().Get("Vary"); vary != "Origin" {
+					t.Errorf("Vary %q, want Origin", vary)
+				}
+				if credentials := w.Header().Get("Access-Control-Allow-Credentials"); credentials != "true" {
+					t.Errorf("Access-Control-Allow-Credentials %q, want true", credentials)
+				}
+			}
+			preflight := test.method == http.MethodOptions && test.wantStatus == http.StatusNoContent
+			if methods := w.Header().Get("Access-Control-Allow-Methods"); preflight != strings.Contains(methods, "POST") {
+				t.Errorf("Access-Control-Allow-Methods %q, want POST only for preflight", methods)
+			}
+			wantHandled := test.method == http.MethodPost && test.wantStatus == http.StatusNoContent
+			if handled != wantHandled {
+				t.Errorf("handled %t, want %t", handled, wantHandled)
+			}
+		})
+	}
+}
+
+func TestWebSocketUpgradeChecksOrigin(t *testing.T) {
+	upgrader := websocket.Upgrader{CheckOrigin: newTestOriginPolicy(t, "https://relay.example.com").CheckOrigin}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		_ = conn.Close()
+	}))
+	defer server.Close()
+	url := "ws" + strings.TrimPrefix(server.URL, "http")
+
+	tests := []struct {
+		name   string
+		origin string
+		want   int
+	}{
+		{"no origin", "", http.StatusSwitchingProtocols},
+		{"same host", server.URL, http.StatusSwitchingProtocols},
+		{"allow-listed", "https://relay.example.com", http.StatusSwitchingProtocols},
+		{"foreign", "https://evil.example.com", http.StatusForbidden},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			header := http.Header{}
+			if test.origin != "" {
+				header.Set("Origin", test.origin)
+			}
+			conn, response, err := websocket.DefaultDialer.Dial(url, header)
+			if conn != nil {
+				_ = conn.Close()
+			}
+			if response == nil {
+				t.Fatalf("no response (%v)", err)
+			}
+			if response.StatusCode != test.want {
+				t.Errorf("status %d, want %d (%v)", response.StatusCode, test.want, err)
+			}
+		})
+	}
+}
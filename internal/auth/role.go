@@ -0,0 +1,25 @@
+package auth
+
+type Role string
+
+const (
+	RoleAudience    Role = "audience"
+	RolePresenter   Role = "presenter"
+	RoleModerator   Role = "moderator"
+	RoleTranscriber Role = "transcriber"
+	RoleAdmin       Role = "admin"
+)
+
+func (r Role) Valid() bool {
+	switch r {
+	case RoleAudience, RolePresenter, RoleModerator, RoleTranscriber, RoleAdmin:
+		return true
+	default:
+		return false
+	}
+}
+
+// Everyone is in the audience, and admins may act in any role.
+func (r Role) allows(required Role) bool {
+	return required == RoleAudience || r == required || r == RoleAdmin
+}
@@ -0,0 +1,58 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Signed session cookie values, of the form "role.expiry.signature".
+type sessions struct {
+	secret []byte
+	ttl    time.Duration
+}
+
+func (s sessions) sign(payload string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(payload))
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (s sessions) issue(role Role, now time.Time) string {
+	payload := string(role) + "." + strconv.FormatInt(now.Add(s.ttl).Unix(), 10)
+
+	return payload + "." + s.sign(payload)
+}
+
+func (s sessions) verify(value string, now time.Time) (Role, bool) {
+	lastDot := strings.LastIndex(value, ".")
+	if lastDot == -1 {
+		return "", false
+	}
+	payload, signature := value[:lastDot], value[lastDot+1:]
+	if !hmac.Equal([]byte(signature), []byte(s.sign(payload))) {
+		return "", false
+	}
+	role, expiry, ok := strings.Cut(payload, ".")
+	if !ok {
+		return "", false
+	}
+	expiresAt, err := strconv.ParseInt(expiry, 10, 64)
+	if err != nil || now.Unix() >= expiresAt || !Role(role).Valid() {
+		return "", false
+	}
+
+	return Role(role), true
+}
+
+func randomSecret() ([]byte, error) {
+	secret := make([]byte, 32)
+	_, err := rand.Read(secret)
+
+	return secret, err
+}
@@ -1,6 +1,7 @@
 package chat
 
 import (
+	"context"
 	"log"
 	"presentation-service/internal/notification"
 )
@@ -15,14 +16,15 @@ func (b *Broadcaster) NewMessage(message Message) {
 	b.notification.NotifyAll(message)
 }
 
-func (b *Broadcaster) Subscribe(subscriber chan<- Message) {
-	numSubs := b.notification.Subscribe(subscriber)
-	log.Printf("+1 %s message notification (=%d)", b.name, numSubs)
-}
+func (b *Broadcaster) Subscribe(
+	ctx context.Context, policy notification.DeliveryPolicy,
+) (<-chan Message, error) {
+	subscription, err := b.notification.Subscribe(ctx, policy)
+	if err != nil {
+		return nil, err
+	}
 
-func (b *Broadcaster) Unsubscribe(subscriber chan<- Message) {
-	numSubs := b.notification.Unsubscribe(subscriber)
-	log.Printf("-1 %s message notification (=%d)", b.name, numSubs)
+	return notification.LogSubscriber(b.notification, b.name+" message notification", subscription), nil
 }
 
 func NewBroadcaster(name string) *Broadcaster {
@@ -1,55 +1,328 @@
 package counter
 
 import (
+	"context"
+	"fmt"
 	lru "github.com/hashicorp/golang-lru/v2"
 	"log"
 	"presentation-service/internal/chat"
 	"presentation-service/internal/notification"
+	"presentation-service/internal/token"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 )
 
-const batchPeriodMillis = 100
+const (
+	batchPeriodMillis = 100
+	historyCapacity   = 1024
+)
 
 type SendersByTokenCounter struct {
-	name                       string
-	extractTokens              func(string) []string
-	tokensPerSender            int
-	tokensBySender             map[string]*lru.Cache[string, struct{}]
-	tokens                     multiSet[string]
-	mutex                      sync.RWMutex
-	initialCapacity            int
-	messages                   chan chat.Message
-	chatMessageBroadcaster     *chat.Broadcaster
-	rejectedMessageBroadcaster *chat.Broadcaster
-	notification               *notification.Notification[Counts]
-	awaitingNotify             bool
-	awaitingNotifyMutex        sync.Mutex
+	name                string
+	extractTokens       func(string) []string
+	tokensPerSender     int
+	topN                int
+	counting            Counting
+	hideUntilReveal     bool
+	ratingScale         *token.RatingScale // If a rating poll
+	voting              Voting
+	state               State
+	tokensBySender      map[string]*lru.Cache[string, time.Time] // Token to vote time
+	anonymousVoters     int                                      // Messages without a sender, each a voter
+	tokens              multiSet[string]
+	votes               []vote                   // Oldest first, if windowed
+	scoresByToken       map[string]*decayedScore // If decaying
+	scoresNotifiedAt    time.Time                // When decayed scores last changed materially
+	changedAt           time.Time                // When counts last changed, by message time for votes
+	unrecordedSince     time.Time                // Earliest change not yet in history, zero if none
+	mutex               sync.RWMutex
+	initialCapacity     int
+	notification        *notification.SequencedNotification[Counts]
+	awaitingNotify      bool
+	awaitingNotifyMutex sync.Mutex
+	history             []Snapshot
+	historyMutex        sync.Mutex
+}
+
+func maxDuration(a, b time.Duration) time.Duration {
+	if a > b {
+		return a
+	}
+
+	return b
+}
+
+func percent(numerator, denominator float64) float64 {
+	if denominator == 0 {
+		return 0
+	}
+
+	return 100 * numerator / denominator
+}
+
+// Messages without a sender (from the moderator) are each counted as a
+// separate voter, so that every vote has a voter.
+func anonymousSender(n int) string {
+	return fmt.Sprintf("\x00anonymous-%d", n)
+}
+
+// Caller must hold mutex
+func (c *SendersByTokenCounter) countVoters(tokens map[string]struct{}) int {
+	voters := 0
+	for _, senderTokens := range c.tokensBySender {
+		for _, token := range senderTokens.Keys() {
+			if _, ok := tokens[token]; ok {
+				voters++
+				break
+			}
+		}
+	}
+
+	return voters
 }
 
 func (c *SendersByTokenCounter) copyCounts() Counts {
 	c.mutex.RLock()
 	defer c.mutex.RUnlock()
+
+	return c.counts()
+}
+
+// Caller must hold mutex
+func (c *SendersByTokenCounter) counts() Counts {
+	ranked := c.tokens.ranked()
 	counts := Counts{
-		TokensAndCounts: make(
-			[][]any, 0, len(c.tokens.elementsByCount),
-		),
+		TokensAndCounts: make([][]any, 0, len(ranked)),
+		Results:         make([]Result, 0, len(ranked)),
+		Voters:          len(c.tokensBySender),
+		State:           c.state,
+	}
+	for _, tokenCount := range ranked {
+		counts.Votes += tokenCount.count
+		numTokensAndCounts := len(counts.TokensAndCounts)
+		if numTokensAndCounts == 0 || counts.TokensAndCounts[numTokensAndCounts-1][0] != tokenCount.count {
+			counts.TokensAndCounts = append(
+				counts.TokensAndCounts, []any{tokenCount.count, []string{tokenCount.element}},
+			)
+		} else {
+			last := counts.TokensAndCounts[numTokensAndCounts-1]
+			last[1] = append(last[1].([]string), tokenCount.element)
+		}
+	}
+
+	if c.ratingScale != nil {
+		counts.Rating = newRatingStats(*c.ratingScale, ranked)
+	}
+
+	var ballots [][]string
+	if c.voting.ranked() {
+		ballots = c.ballots()
+	}
+	if c.voting.Mode == VotingInstantRunoff {
+		counts.Runoff = instantRunoff(ballots)
+	}
+
+	// Results are ranked by count, or by score if decaying or weighted
+	scored := c.counting.decaying() || c.voting.Mode == VotingWeighted
+	weights := make(map[string]float64, len(ranked))
+	if c.voting.Mode == VotingWeighted {
+		for _, ballot := range ballots {
+			for preference, token := range ballot {
+				weights[token] += c.voting.weight(preference)
+			}
+		}
+	}
+	totalWeight := 0.0
+	now := time.Now()
+	for _, tokenCount := range ranked {
+		weight := float64(tokenCount.count)
+		if c.counting.decaying() {
+			weight = 0
+			if score, ok := c.scoresByToken[tokenCount.element]; ok {
+				weight = score.valueAt(c.counting, now)
+			}
+		} else if c.voting.Mode == VotingWeighted {
+			weight = weights[tokenCount.element]
+		}
+		weights[tokenCount.element] = weight
+		totalWeight += weight
+	}
+	if scored {
+		sort.SliceStable(ranked, func(i, j int) bool {
+			return weights[ranked[i].element] > weights[ranked[j].element]
+		})
+	}
+	score := func(weight float64) float64 {
+		if scored {
+			return weight
+		}
+		return 0
+	}
+
+	rank := 0
+	for i, tokenCount := range ranked {
+		weight := weights[tokenCount.element]
+		if i == 0 || weights[ranked[i-1].element] != weight {
+			rank = i + 1
+		}
+		if c.topN > 0 && i >= c.topN {
+			break
+		}
+		counts.Results = append(counts.Results, Result{
+			Rank:            rank,
+			Token:           tokenCount.element,
+			Count:           tokenCount.count,
+			Score:           score(weight),
+			PercentOfVoters: percent(float64(tokenCount.count), float64(counts.Voters)),
+			PercentOfVotes:  percent(weight, totalWeight),
+		})
 	}
-	// safe deep copy
-	for count, items := range c.tokens.elementsByCount {
-		itemsCopy := make([]string, len(items))
-		copy(itemsCopy, items)
-		counts.TokensAndCounts = append(
-			counts.TokensAndCounts, []any{count, itemsCopy},
-		)
+
+	if c.topN > 0 && len(ranked) > c.topN {
+		otherCount := 0
+		otherWeight := 0.0
+		otherTokens := make(map[string]struct{}, len(ranked)-c.topN)
+		for _, tokenCount := range ranked[c.topN:] {
+			otherCount += tokenCount.count
+			otherWeight += weights[tokenCount.element]
+			otherTokens[tokenCount.element] = struct{}{}
+		}
+		counts.Results = append(counts.Results, Result{
+			Rank:            c.topN + 1,
+			Token:           OtherToken,
+			Count:           otherCount,
+			Score:           score(otherWeight),
+			PercentOfVoters: percent(float64(c.countVoters(otherTokens)), float64(counts.Voters)),
+			PercentOfVotes:  percent(otherWeight, totalWeight),
+		})
 	}
 
 	return counts
 }
 
+// Each sender's tokens, most preferred first.
+// Caller must hold mutex
+func (c *SendersByTokenCounter) ballots() [][]string {
+	ballots := make([][]string, 0, len(c.tokensBySender))
+	for _, tokens := range c.tokensBySender {
+		oldestFirst := tokens.Keys()
+		ballot := make([]string, len(oldestFirst))
+		for i, token := range oldestFirst {
+			ballot[len(oldestFirst)-1-i] = token
+		}
+		ballots = append(ballots, ballot)
+	}
+
+	return ballots
+}
+
+// Hides results from counts if the poll is yet to be revealed.
+func (c *SendersByTokenCounter) presentable(counts Counts) Counts {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	if !c.hideUntilReveal || c.state == StateRevealed {
+		return counts
+	}
+	counts.TokensAndCounts = [][]any{}
+	counts.Results = []Result{}
+	counts.Rating = nil
+	counts.Runoff = nil
+	counts.Hidden = true
+
+	return counts
+}
+
+func (c *SendersByTokenCounter) recordSnapshot(at time.Time, counts Counts) {
+	c.historyMutex.Lock()
+	defer c.historyMutex.Unlock()
+	// Halve the resolution when full, so history always spans the whole poll
+	if len(c.history) >= historyCapacity {
+		thinned := c.history[:0]
+		for i := (len(c.history) - 1) % 2; i < len(c.history); i += 2 {
+			thinned = append(thinned, c.history[i])
+		}
+		c.history = thinned
+	}
+	c.history = append(c.history, Snapshot{Time: at, Counts: counts})
+}
+
+// Notes a change at the given time, to be recorded in history by the next
+// notification.
+// Caller must hold mutex
+func (c *SendersByTokenCounter) changed(at time.Time) {
+	c.changedAt = at
+	if c.unrecordedSince.IsZero() {
+		c.unrecordedSince = at
+	}
+}
+
+// Records changes more than a batch period before the given time, as they
+// would have been notified by then. Replayed messages arrive faster than
+// notifications, so this keeps their history batched by message time.
+// Caller must hold mutex
+func (c *SendersByTokenCounter) recordBatchBefore(at time.Time) {
+	if !c.unrecordedSince.IsZero() && at.Sub(c.unrecordedSince) >= batchPeriodMillis*time.Millisecond {
+		c.recordSnapshot(c.changedAt, c.counts())
+		c.unrecordedSince = time.Time{}
+	}
+}
+
 func (c *SendersByTokenCounter) notifyAllSubscribers() {
-	c.notification.NotifyAll(c.copyCounts())
+	c.mutex.Lock()
+	counts := c.counts()
+	if !c.unrecordedSince.IsZero() {
+		c.recordSnapshot(c.changedAt, counts)
+		c.unrecordedSince = time.Time{}
+	}
+	c.mutex.Unlock()
+	c.notification.NotifyAll(c.presentable(counts))
+}
+
+// One snapshot per batch of changes since the last reset, oldest first,
+// stamped with the time of the last change in the batch.
+func (c *SendersByTokenCounter) History() []Snapshot {
+	c.historyMutex.Lock()
+	history := make([]Snapshot, len(c.history))
+	copy(history, c.history)
+	c.historyMutex.Unlock()
+	for i := range history {
+		history[i].Counts = c.presentable(history[i].Counts)
+	}
+
+	return history
+}
+
+// Streams History evenly over duration, closing the channel after the last
+// snapshot, or when ctx is cancelled.
+func (c *SendersByTokenCounter) Replay(ctx context.Context, duration time.Duration) <-chan Counts {
+	history := c.History()
+	counts := make(chan Counts)
+	go func() {
+		defer close(counts)
+		if len(history) == 0 {
+			return
+		}
+		ticker := time.NewTicker(maxDuration(duration/time.Duration(len(history)), time.Millisecond))
+		defer ticker.Stop()
+		for i, snapshot := range history {
+			if i > 0 {
+				select {
+				case <-ticker.C:
+				case <-ctx.Done():
+					return
+				}
+			}
+			select {
+			case counts <- snapshot.Counts:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return counts
 }
 
 func (c *SendersByTokenCounter) scheduleNotification() {
@@ -66,44 +339,168 @@ func (c *SendersByTokenCounter) scheduleNotification() {
 	}
 }
 
-func (c *SendersByTokenCounter) NewMessage(message chat.Message) {
+// Caller must hold mutex
+func (c *SendersByTokenCounter) recordVote(sender, token string, at, now time.Time) {
+	if c.counting.windowed() {
+		c.votes = append(c.votes, vote{sender: sender, token: token, at: at})
+	}
+	if c.counting.decaying() {
+		score, ok := c.scoresByToken[token]
+		if !ok {
+			score = &decayedScore{at: now}
+			c.scoresByToken[token] = score
+		}
+		score.add(c.counting, c.counting.decay(1, at, now), now)
+	}
+}
+
+// Caller must hold mutex
+func (c *SendersByTokenCounter) forgetVote(token string, at, now time.Time) {
+	if score, ok := c.scoresByToken[token]; ok {
+		score.add(c.counting, -c.counting.decay(1, at, now), now)
+		if score.value < scoreEpsilon {
+			delete(c.scoresByToken, token)
+		}
+	}
+}
+
+// Caller must hold mutex
+func (c *SendersByTokenCounter) expireVotes(now time.Time) bool {
+	cutoff := now.Add(-c.counting.Window)
+	expired := false
+	for len(c.votes) > 0 && c.votes[0].at.Before(cutoff) {
+		v := c.votes[0]
+		c.votes = c.votes[1:]
+		tokens, ok := c.tokensBySender[v.sender]
+		if !ok {
+			continue
+		}
+		// Superseded by a more recent vote for the same token
+		if at, ok := tokens.Peek(v.token); !ok || !at.Equal(v.at) {
+			continue
+		}
+		tokens.Remove(v.token)
+		if tokens.Len() == 0 {
+			delete(c.tokensBySender, v.sender)
+		}
+		c.tokens.update(v.token, -1)
+		expired = true
+	}
+
+	return expired
+}
+
+// Forgets negligible scores, and reports whether scores have changed
+// materially since they were last notified. Decay alone doesn't change ranks,
+// so scores only change materially once they have lost materialDecay of their
+// value, or are forgotten.
+// Caller must hold mutex
+func (c *SendersByTokenCounter) decayScores(now time.Time) bool {
+	forgotten := false
+	for token, score := range c.scoresByToken {
+		if score.valueAt(c.counting, now) < scoreEpsilon {
+			delete(c.scoresByToken, token)
+			forgotten = true
+		}
+	}
+	if !forgotten &&
+		(len(c.scoresByToken) == 0 || c.counting.decay(1, c.scoresNotifiedAt, now) > 1-materialDecay) {
+		return false
+	}
+	c.scoresNotifiedAt = now
+
+	return true
+}
+
+func (c *SendersByTokenCounter) tick(now time.Time) {
+	c.mutex.Lock()
+	c.recordBatchBefore(now)
+	changed := c.counting.decaying() && c.decayScores(now)
+	if c.counting.windowed() && c.expireVotes(now) {
+		changed = true
+	}
+	if changed {
+		c.changed(now)
+	}
+	c.mutex.Unlock()
+
+	if changed {
+		c.scheduleNotification()
+	}
+}
+
+// Decays scores and expires votes outside the window until ctx is done.
+// Returns immediately unless counting is decaying or windowed.
+func (c *SendersByTokenCounter) Run(ctx context.Context) {
+	if !c.counting.windowed() && !c.counting.decaying() {
+		return
+	}
+	ticker := time.NewTicker(countingTickPeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			c.tick(now)
+		}
+	}
+}
+
+// Reports whether the message was counted as a vote. Only open polls count
+// messages.
+func (c *SendersByTokenCounter) NewMessage(message chat.Message) bool {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
+	if c.state != StateOpen {
+		return false
+	}
+	now := time.Now()
+	votedAt := message.Time
+	if votedAt.IsZero() {
+		votedAt = now
+	}
 	extractedTokens := c.extractTokens(message.Text)
 	extractedTokensLen := len(extractedTokens)
 
 	if extractedTokensLen > 0 {
+		c.recordBatchBefore(votedAt)
 		log.Printf(`Extracted token "%s"`, strings.Join(extractedTokens, `", "`))
 		newTokens := make([]string, 0, extractedTokensLen)
 		newTokenSet := map[string]struct{}{}
 		oldTokens := make([]string, 0, extractedTokensLen)
 		oldTokenSet := map[string]struct{}{}
+		sender := message.Sender
+		if sender == "" {
+			c.anonymousVoters++
+			sender = anonymousSender(c.anonymousVoters)
+		}
 		// Iterate in reverse, prioritizing first tokens
 		for i := extractedTokensLen - 1; i >= 0; i-- {
 			extractedToken := extractedTokens[i]
-			if message.Sender == "" {
-				newTokens = append(newTokens, extractedToken)
-			} else {
-				if _, present := c.tokensBySender[message.Sender]; !present {
-					tokens, newLRUError := lru.New[string, struct{}](c.tokensPerSender)
-					if newLRUError != nil {
-						log.Printf("Error creating LRU cache")
-						continue
-					}
-					c.tokensBySender[message.Sender] = tokens
+			if _, present := c.tokensBySender[sender]; !present {
+				tokens, newLRUError := lru.New[string, time.Time](c.tokensPerSender)
+				if newLRUError != nil {
+					log.Printf("Error creating LRU cache")
+					continue
 				}
-				tokens := c.tokensBySender[message.Sender]
-				oldestToken, _, gotOldest := tokens.GetOldest()
-				exists := tokens.Contains(extractedToken)
-				evicted := tokens.Add(extractedToken, struct{}{})
-
-				if !exists {
-					newTokens = append(newTokens, extractedToken)
-					newTokenSet[extractedToken] = struct{}{}
-					if gotOldest && evicted {
-						oldTokens = append(oldTokens, oldestToken)
-						oldTokenSet[oldestToken] = struct{}{}
-					}
+				c.tokensBySender[sender] = tokens
+			}
+			tokens := c.tokensBySender[sender]
+			oldestToken, oldestVotedAt, gotOldest := tokens.GetOldest()
+			previouslyVotedAt, exists := tokens.Peek(extractedToken)
+			evicted := tokens.Add(extractedToken, votedAt)
+			c.recordVote(sender, extractedToken, votedAt, now)
+
+			if exists {
+				c.forgetVote(extractedToken, previouslyVotedAt, now)
+			} else {
+				newTokens = append(newTokens, extractedToken)
+				newTokenSet[extractedToken] = struct{}{}
+				if gotOldest && evicted {
+					c.forgetVote(oldestToken, oldestVotedAt, now)
+					oldTokens = append(oldTokens, oldestToken)
+					oldTokenSet[oldestToken] = struct{}{}
 				}
 			}
 		}
@@ -120,63 +517,110 @@ func (c *SendersByTokenCounter) NewMessage(message chat.Message) {
 			}
 		}
 
+		c.scoresNotifiedAt = now
+		c.changed(votedAt)
 		c.scheduleNotification()
-	} else {
-		log.Printf("No token extracted")
-		c.rejectedMessageBroadcaster.NewMessage(message)
+		return true
 	}
+
+	return false
 }
 
-func (c *SendersByTokenCounter) Subscribe(subscriber chan<- Counts) {
-	go func() {
-		subscriber <- c.copyCounts()
-	}()
-	if c.messages == nil {
-		c.messages = make(chan chat.Message)
-		c.chatMessageBroadcaster.Subscribe(c.messages)
-		go func() {
-			for msg := range c.messages {
-				c.NewMessage(msg)
-			}
-		}()
+// Counting happens regardless of subscribers. Current counts are sent first,
+// unless afterSeq is the latest.
+func (c *SendersByTokenCounter) Subscribe(
+	ctx context.Context, policy notification.DeliveryPolicy, afterSeq uint64,
+) (<-chan notification.Sequenced[Counts], error) {
+	subscription, err := c.notification.SubscribeWithCurrent(ctx, policy, afterSeq, func() Counts {
+		return c.presentable(c.copyCounts())
+	})
+	if err != nil {
+		return nil, err
 	}
-	numSubs := c.notification.Subscribe(subscriber)
-	log.Printf("+1 %s notification (=%d)", c.name, numSubs)
+
+	return notification.LogSubscriber(c.notification, c.name+" notification", subscription), nil
+}
+
+func (c *SendersByTokenCounter) State() State {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	return c.state
 }
 
-func (c *SendersByTokenCounter) Unsubscribe(subscriber chan<- Counts) {
-	numSubs := c.notification.Unsubscribe(subscriber)
-	if numSubs == 0 {
-		c.chatMessageBroadcaster.Unsubscribe(c.messages)
-		c.messages = nil
+// Moves the poll to state at the given time. Returns ErrInvalidTransition if
+// the poll cannot move to state.
+func (c *SendersByTokenCounter) SetState(state State, at time.Time) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if err := c.state.transitionTo(state); err != nil {
+		return err
 	}
-	log.Printf("-1 %s notification (=%d)", c.name, numSubs)
+	log.Printf("Poll %s %s (was %s)", c.name, state, c.state)
+	c.recordBatchBefore(at)
+	c.state = state
+	c.changed(at)
+	c.scheduleNotification()
+
+	return nil
 }
 
 func (c *SendersByTokenCounter) Reset() {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
-	c.tokensBySender = make(map[string]*lru.Cache[string, struct{}], c.initialCapacity)
+	c.tokensBySender = make(map[string]*lru.Cache[string, time.Time], c.initialCapacity)
+	c.anonymousVoters = 0
 	c.tokens = newMultiSet[string](c.initialCapacity)
+	c.votes = nil
+	c.scoresByToken = map[string]*decayedScore{}
+	c.scoresNotifiedAt = time.Now()
+	c.historyMutex.Lock()
+	c.history = nil
+	c.historyMutex.Unlock()
+	c.unrecordedSince = time.Time{}
 
 	c.scheduleNotification()
 }
 
+// If topN is positive, only the top N results are reported individually,
+// with the remainder combined as OtherToken. Polls start in
+// lifecycle.InitialState, or StateOpen if it is empty. Rating polls also
+// report RatingStats, if ratingScale is not nil. Votes are counted by
+// plurality if voting.Mode is empty. Decaying and windowed polls only change
+// over time while Run.
 func NewSendersByTokenActor(
-	name string, tokensPerSender int, extractTokens func(string) []string,
-	chatMessageBroadcaster, rejectedMessageBroadcaster *chat.Broadcaster,
+	name string, tokensPerSender, topN int, counting Counting, lifecycle Lifecycle,
+	ratingScale *token.RatingScale, voting Voting, extractTokens func(string) []string,
 	initialCapacity int,
 ) *SendersByTokenCounter {
-	return &SendersByTokenCounter{
-		name:                       name,
-		extractTokens:              extractTokens,
-		tokensPerSender:            tokensPerSender,
-		tokensBySender:             make(map[string]*lru.Cache[string, struct{}], initialCapacity),
-		tokens:                     newMultiSet[string](initialCapacity),
-		initialCapacity:            initialCapacity,
-		chatMessageBroadcaster:     chatMessageBroadcaster,
-		rejectedMessageBroadcaster: rejectedMessageBroadcaster,
-		notification:               notification.NewNotification[Counts](),
-		awaitingNotify:             false,
+	c := &SendersByTokenCounter{
+		name:            name,
+		extractTokens:   extractTokens,
+		tokensPerSender: tokensPerSender,
+		topN:            topN,
+		counting:        counting,
+		hideUntilReveal: lifecycle.HideUntilReveal,
+		ratingScale:     ratingScale,
+		voting:          voting,
+		state:           lifecycle.InitialState,
+		tokensBySender:  make(map[string]*lru.Cache[string, time.Time], initialCapacity),
+		tokens:          newMultiSet[string](initialCapacity),
+		scoresByToken:   map[string]*decayedScore{},
+		initialCapacity: initialCapacity,
+		notification:    notification.NewSequencedNotification[Counts](1),
+		awaitingNotify:  false,
+	}
+	if c.state == "" {
+		c.state = StateOpen
+	}
+	if c.voting.Mode == "" {
+		c.voting.Mode = VotingPlurality
+	}
+	if len(c.voting.Weights) == 0 {
+		c.voting.Weights = make([]float64, tokensPerSender)
+		for i := range c.voting.Weights {
+			c.voting.Weights[i] = float64(tokensPerSender - i)
+		}
 	}
+	return c
 }
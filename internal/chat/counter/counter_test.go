@@ -0,0 +1,274 @@
+package counter
+
+import (
+	"context"
+	"presentation-service/internal/chat"
+	"presentation-service/internal/token"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDecayScoresOnlyChangesMaterially(t *testing.T) {
+	c := NewSendersByTokenActor(
+		"decaying", 1, 0, Counting{HalfLife: time.Minute}, Lifecycle{}, nil, Voting{},
+		token.NewVocabularyExtractor(map[string]string{"vim": "Vim"}), 0,
+	)
+	if !c.NewMessage(chat.Message{Sender: "Jane", Text: "vim"}) {
+		t.Fatal("vote not counted")
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	votedAt := c.scoresNotifiedAt
+
+	if c.decayScores(votedAt.Add(time.Second)) {
+		t.Error("changed after 1s, want unchanged until decayed by materialDecay")
+	}
+	if !c.decayScores(votedAt.Add(10 * time.Second)) {
+		t.Error("unchanged after 10s, want changed")
+	}
+	if c.decayScores(votedAt.Add(11 * time.Second)) {
+		t.Error("changed 1s after last change, want unchanged")
+	}
+	if !c.decayScores(votedAt.Add(time.Hour)) {
+		t.Error("unchanged after an hour, want negligible score forgotten")
+	}
+	if len(c.scoresByToken) != 0 {
+		t.Errorf("%d scores, want negligible scores forgotten", len(c.scoresByToken))
+	}
+	if c.decayScores(votedAt.Add(2 * time.Hour)) {
+		t.Error("changed without scores, want unchanged")
+	}
+}
+
+func TestMessagesWithoutSenderAreEachAVoter(t *testing.T) {
+	c := NewSendersByTokenActor(
+		"language-poll", 2, 0, Counting{}, Lifecycle{}, nil, Voting{},
+		token.NewVocabularyExtractor(map[string]string{"go": "Go", "rust": "Rust"}), 0,
+	)
+	for _, message := range []chat.Message{
+		{Sender: "Jane", Text: "go"},
+		{Sender: "Bob", Text: "rust"},
+		{Sender: "", Text: "go"},
+		{Sender: "", Text: "go and rust"},
+	} {
+		c.NewMessage(message)
+	}
+
+	counts := c.copyCounts()
+
+	if counts.Voters != 4 || counts.Votes != 5 {
+		t.Errorf("%d voters and %d votes, want 4 voters and 5 votes", counts.Voters, counts.Votes)
+	}
+	want := map[string]float64{"Go": 75, "Rust": 50}
+	for _, result := range counts.Results {
+		if result.PercentOfVoters != want[result.Token] {
+			t.Errorf("%s has %.1f%% of voters, want %.1f%%", result.Token, result.PercentOfVoters, want[result.Token])
+		}
+	}
+}
+
+func TestHistoryThinsToSpanWholePoll(t *testing.T) {
+	c := newLifecyclePoll(Lifecycle{})
+	for votes := 1; votes <= historyCapacity+1; votes++ {
+		c.recordSnapshot(time.Now(), Counts{Votes: votes})
+	}
+
+	history := c.History()
+	if len(history) != historyCapacity/2+1 {
+		t.Fatalf("%d snapshots, want %d", len(history), historyCapacity/2+1)
+	}
+	// Every other snapshot is kept, including the latest before thinning
+	for i, snapshot := range history[:historyCapacity/2] {
+		if want := 2 * (i + 1); snapshot.Counts.Votes != want {
+			t.Fatalf("snapshot %d has %d votes, want %d", i, snapshot.Counts.Votes, want)
+		}
+	}
+	if last := history[len(history)-1]; last.Counts.Votes != historyCapacity+1 {
+		t.Errorf("last snapshot has %d votes, want %d", last.Counts.Votes, historyCapacity+1)
+	}
+
+	c.Reset()
+	if history = c.History(); len(history) != 0 {
+		t.Errorf("%d snapshots after reset, want none", len(history))
+	}
+}
+
+func TestReplayStreamsHistoryInOrder(t *testing.T) {
+	c := newLifecyclePoll(Lifecycle{})
+	// As replayed from the event log, faster than notifications
+	start := time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)
+	for i, sender := range []string{"Jane", "Bob", "Ann"} {
+		c.NewMessage(chat.Message{Sender: sender, Text: "go", Time: start.Add(time.Duration(i) * time.Minute)})
+	}
+	if err := c.SetState(StateClosed, start.Add(150*time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	c.notifyAllSubscribers()
+
+	history := c.History()
+	times := make([]time.Time, 0, len(history))
+	for _, snapshot := range history {
+		times = append(times, snapshot.Time)
+	}
+	wantTimes := []time.Time{
+		start, start.Add(time.Minute), start.Add(2 * time.Minute), start.Add(150 * time.Second),
+	}
+	if !reflect.DeepEqual(times, wantTimes) {
+		t.Errorf("snapshots at %v, want the message times %v", times, wantTimes)
+	}
+
+	var replayed []int
+	for counts := range c.Replay(context.Background(), 30*time.Millisecond) {
+		replayed = append(replayed, counts.Votes)
+	}
+	if !reflect.DeepEqual(replayed, []int{1, 2, 3, 3}) {
+		t.Errorf("replayed %v, want [1 2 3 3]", replayed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	counts := c.Replay(ctx, time.Hour)
+	if first := <-counts; first.Votes != 1 {
+		t.Errorf("replayed %d votes first, want 1", first.Votes)
+	}
+	cancel()
+	select {
+	case _, ok := <-counts:
+		if ok {
+			t.Error("replayed the next snapshot, want replay stopped when cancelled")
+		}
+	case <-time.After(time.Second):
+		t.Error("replay not stopped when cancelled")
+	}
+}
+
+func TestResults(t *testing.T) {
+	votes := []string{"go", "go", "rust", "elm", "go", "rust", "elm", "java"}
+	for _, test := range []struct {
+		name string
+		topN int
+		want []Result
+	}{
+		{
+			name: "all",
+			want: []Result{
+				{Rank: 1, Token: "Go", Count: 3, PercentOfVoters: 37.5, PercentOfVotes: 37.5},
+				{Rank: 2, Token: "Rust", Count: 2, PercentOfVoters: 25, PercentOfVotes: 25},
+				{Rank: 2, Token: "Elm", Count: 2, PercentOfVoters: 25, PercentOfVotes: 25},
+				{Rank: 4, Token: "Java", Count: 1, PercentOfVoters: 12.5, PercentOfVotes: 12.5},
+			},
+		},
+		{
+			name: "top N without overflow",
+			topN: 4,
+			want: []Result{
+				{Rank: 1, Token: "Go", Count: 3, PercentOfVoters: 37.5, PercentOfVotes: 37.5},
+				{Rank: 2, Token: "Rust", Count: 2, PercentOfVoters: 25, PercentOfVotes: 25},
+				{Rank: 2, Token: "Elm", Count: 2, PercentOfVoters: 25, PercentOfVotes: 25},
+				{Rank: 4, Token: "Java", Count: 1, PercentOfVoters: 12.5, PercentOfVotes: 12.5},
+			},
+		},
+		{
+			name: "top N with overflow",
+			topN: 3,
+			want: []Result{
+				{Rank: 1, Token: "Go", Count: 3, PercentOfVoters: 37.5, PercentOfVotes: 37.5},
+				{Rank: 2, Token: "Rust", Count: 2, PercentOfVoters: 25, PercentOfVotes: 25},
+				{Rank: 2, Token: "Elm", Count: 2, PercentOfVoters: 25, PercentOfVotes: 25},
+				{Rank: 4, Token: OtherToken, Count: 1, PercentOfVoters: 12.5, PercentOfVotes: 12.5},
+			},
+		},
+		{
+			name: "top N splitting a tie",
+			topN: 2,
+			want: []Result{
+				{Rank: 1, Token: "Go", Count: 3, PercentOfVoters: 37.5, PercentOfVotes: 37.5},
+				{Rank: 2, Token: "Rust", Count: 2, PercentOfVoters: 25, PercentOfVotes: 25},
+				{Rank: 3, Token: OtherToken, Count: 3, PercentOfVoters: 37.5, PercentOfVotes: 37.5},
+			},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			c := NewSendersByTokenActor(
+				"language-poll", 1, test.topN, Counting{}, Lifecycle{}, nil, Voting{},
+				token.NewVocabularyExtractor(map[string]string{"go": "Go", "rust": "Rust", "elm": "Elm", "java": "Java"}), 0,
+			)
+			for i, vote := range votes {
+				c.NewMessage(chat.Message{Sender: string(rune('A' + i)), Text: vote})
+			}
+
+			counts := c.copyCounts()
+
+			if !reflect.DeepEqual(counts.Results, test.want) {
+				t.Errorf("results are %+v, want %+v", counts.Results, test.want)
+			}
+			totalPercent := 0.0
+			for _, result := range counts.Results {
+				totalPercent += result.PercentOfVotes
+			}
+			if totalPercent != 100 {
+				t.Errorf("percentages of votes add up to %.1f%%, want 100%%", totalPercent)
+			}
+		})
+	}
+}
+
+func TestWindowExpiresVotes(t *testing.T) {
+	c := NewSendersByTokenActor(
+		"windowed", 1, 0, Counting{Window: time.Minute}, Lifecycle{}, nil, Voting{},
+		token.NewVocabularyExtractor(map[string]string{"go": "Go", "rust": "Rust"}), 0,
+	)
+	start := time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)
+	for _, message := range []chat.Message{
+		{Sender: "Jane", Text: "go", Time: start},
+		{Sender: "Bob", Text: "go", Time: start.Add(10 * time.Second)},
+		{Sender: "Ann", Text: "rust", Time: start.Add(30 * time.Second)},
+		// Renews Jane's vote
+		{Sender: "Jane", Text: "go", Time: start.Add(45 * time.Second)},
+	} {
+		c.NewMessage(message)
+	}
+
+	for _, test := range []struct {
+		after      time.Duration
+		wantVoters int
+		want       map[string]int
+	}{
+		{after: 50 * time.Second, wantVoters: 3, want: map[string]int{"Go": 2, "Rust": 1}},
+		{after: 71 * time.Second, wantVoters: 2, want: map[string]int{"Go": 1, "Rust": 1}},
+		{after: 100 * time.Second, wantVoters: 1, want: map[string]int{"Go": 1}},
+		{after: 106 * time.Second, wantVoters: 0, want: map[string]int{}},
+	} {
+		c.tick(start.Add(test.after))
+
+		counts := c.copyCounts()
+		got := map[string]int{}
+		for _, result := range counts.Results {
+			got[result.Token] = result.Count
+		}
+		if counts.Voters != test.wantVoters || !reflect.DeepEqual(got, test.want) {
+			t.Errorf("after %s, %d voters counted %v, want %d voters counted %v",
+				test.after, counts.Voters, got, test.wantVoters, test.want)
+		}
+	}
+}
+
+func TestRunStopsWhenDone(t *testing.T) {
+	c := NewSendersByTokenActor(
+		"windowed", 1, 0, Counting{Window: time.Minute}, Lifecycle{}, nil, Voting{},
+		token.NewVocabularyExtractor(map[string]string{"go": "Go"}), 0,
+	)
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		c.Run(ctx)
+		close(stopped)
+	}()
+
+	cancel()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("still running after ctx was done")
+	}
+}
@@ -0,0 +1,56 @@
+package counter
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	countingTickPeriod = time.Second
+	// Decayed scores below this are forgotten
+	scoreEpsilon = 1e-3
+	// Fraction of their value scores lose to decay before subscribers are notified
+	materialDecay = 0.05
+)
+
+// Cumulative if neither Window nor HalfLife are positive.
+type Counting struct {
+	// Only votes cast within the last Window are counted
+	Window time.Duration
+	// Votes lose half their weight every HalfLife
+	HalfLife time.Duration
+}
+
+func (c Counting) windowed() bool {
+	return c.Window > 0
+}
+
+func (c Counting) decaying() bool {
+	return c.HalfLife > 0
+}
+
+func (c Counting) decay(value float64, from, to time.Time) float64 {
+	return value * math.Exp2(-to.Sub(from).Seconds()/c.HalfLife.Seconds())
+}
+
+type vote struct {
+	sender string
+	token  string
+	at     time.Time
+}
+
+// Non-threadsafe - only share copies!
+type decayedScore struct {
+	value float64
+	at    time.Time
+}
+
+// Mutates state
+func (s *decayedScore) add(counting Counting, delta float64, now time.Time) {
+	s.value = s.valueAt(counting, now) + delta
+	s.at = now
+}
+
+func (s *decayedScore) valueAt(counting Counting, now time.Time) float64 {
+	return counting.decay(s.value, s.at, now)
+}
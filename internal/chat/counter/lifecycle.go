@@ -0,0 +1,52 @@
+package counter
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrInvalidTransition = errors.New("invalid poll state transition")
+
+type State string
+
+const (
+	StateScheduled State = "scheduled" // Ignoring chat until opened
+	StateOpen      State = "open"
+	StateClosed    State = "closed"   // Late votes are rejected
+	StateRevealed  State = "revealed" // Closed, with results shown
+)
+
+func (s State) Valid() bool {
+	return s == StateScheduled || s == StateOpen || s == StateClosed || s == StateRevealed
+}
+
+// Polls may be reopened after closing, but never rescheduled.
+func (s State) canTransitionTo(to State) bool {
+	switch to {
+	case StateOpen:
+		return true
+	case StateClosed:
+		return s == StateOpen
+	case StateRevealed:
+		return s == StateOpen || s == StateClosed
+	default:
+		return false
+	}
+}
+
+func (s State) transitionTo(to State) error {
+	if s == to {
+		return nil
+	}
+	if !to.Valid() || !s.canTransitionTo(to) {
+		return fmt.Errorf(`%w from "%s" to "%s"`, ErrInvalidTransition, s, to)
+	}
+
+	return nil
+}
+
+type Lifecycle struct {
+	InitialState State
+	// Only vote and voter totals are reported until the poll is revealed
+	HideUntilReveal bool
+}
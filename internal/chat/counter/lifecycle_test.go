@@ -0,0 +1,107 @@
+package counter
+
+import (
+	"errors"
+	"presentation-service/internal/chat"
+	"presentation-service/internal/token"
+	"testing"
+	"time"
+)
+
+func TestStateTransitions(t *testing.T) {
+	for _, test := range []struct {
+		from, to State
+		valid    bool
+	}{
+		{from: StateScheduled, to: StateScheduled, valid: true},
+		{from: StateScheduled, to: StateOpen, valid: true},
+		{from: StateScheduled, to: StateClosed, valid: false},
+		{from: StateScheduled, to: StateRevealed, valid: false},
+		{from: StateOpen, to: StateScheduled, valid: false},
+		{from: StateOpen, to: StateClosed, valid: true},
+		{from: StateOpen, to: StateRevealed, valid: true},
+		{from: StateClosed, to: StateOpen, valid: true},
+		{from: StateClosed, to: StateRevealed, valid: true},
+		{from: StateClosed, to: StateScheduled, valid: false},
+		{from: StateRevealed, to: StateOpen, valid: true},
+		{from: StateRevealed, to: StateClosed, valid: false},
+		{from: StateOpen, to: "finished", valid: false},
+	} {
+		err := test.from.transitionTo(test.to)
+		if test.valid && err != nil {
+			t.Errorf("%s to %s failed (%v)", test.from, test.to, err)
+		}
+		if !test.valid && !errors.Is(err, ErrInvalidTransition) {
+			t.Errorf("%s to %s returned %v, want ErrInvalidTransition", test.from, test.to, err)
+		}
+	}
+}
+
+func newLifecyclePoll(lifecycle Lifecycle) *SendersByTokenCounter {
+	return NewSendersByTokenActor(
+		"language-poll", 1, 0, Counting{}, lifecycle, nil, Voting{},
+		token.NewVocabularyExtractor(map[string]string{"go": "Go", "rust": "Rust"}), 0,
+	)
+}
+
+func TestPollCountsVotesOnlyWhileOpen(t *testing.T) {
+	c := newLifecyclePoll(Lifecycle{InitialState: StateScheduled})
+	for _, step := range []struct {
+		state   State
+		sender  string
+		counted bool
+	}{
+		{state: StateScheduled, sender: "Jane", counted: false},
+		{state: StateOpen, sender: "Jane", counted: true},
+		{state: StateClosed, sender: "Bob", counted: false},
+		{state: StateOpen, sender: "Bob", counted: true},
+		{state: StateRevealed, sender: "Ann", counted: false},
+	} {
+		if err := c.SetState(step.state, time.Now()); err != nil {
+			t.Fatal(err)
+		}
+		if counted := c.NewMessage(chat.Message{Sender: step.sender, Text: "go"}); counted != step.counted {
+			t.Errorf("%s poll counted %s's vote: %t, want %t", step.state, step.sender, counted, step.counted)
+		}
+	}
+
+	counts := c.copyCounts()
+	if counts.Votes != 2 || counts.State != StateRevealed {
+		t.Errorf("%d votes, %s, want 2 votes, revealed", counts.Votes, counts.State)
+	}
+	if err := c.SetState(StateScheduled, time.Now()); !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("rescheduled the poll (%v), want ErrInvalidTransition", err)
+	}
+}
+
+func TestPollStartsOpen(t *testing.T) {
+	if state := newLifecyclePoll(Lifecycle{}).State(); state != StateOpen {
+		t.Errorf("started %s, want open", state)
+	}
+}
+
+func TestHiddenPollShowsOnlyTotalsUntilRevealed(t *testing.T) {
+	c := newLifecyclePoll(Lifecycle{HideUntilReveal: true})
+	c.NewMessage(chat.Message{Sender: "Jane", Text: "go"})
+	c.NewMessage(chat.Message{Sender: "Bob", Text: "rust"})
+
+	for _, state := range []State{StateOpen, StateClosed} {
+		if err := c.SetState(state, time.Now()); err != nil {
+			t.Fatal(err)
+		}
+		counts := c.presentable(c.copyCounts())
+		if !counts.Hidden || len(counts.Results) != 0 || len(counts.TokensAndCounts) != 0 {
+			t.Errorf("%s poll shows %+v, want results hidden", state, counts)
+		}
+		if counts.Votes != 2 || counts.Voters != 2 {
+			t.Errorf("%s poll has %d votes from %d voters, want totals shown", state, counts.Votes, counts.Voters)
+		}
+	}
+
+	if err := c.SetState(StateRevealed, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if counts := c.presentable(c.copyCounts()); counts.Hidden || len(counts.Results) != 2 {
+		t.Errorf("revealed poll shows %+v, want results", counts)
+	}
+}
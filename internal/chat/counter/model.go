@@ -1,21 +1,156 @@
 package counter
 
 import (
-	"encoding/json"
+	"time"
 )
 
+const OtherToken = "Other"
+
+type Result struct {
+	Rank            int     `json:"rank"` // Equal counts share a rank
+	Token           string  `json:"token"`
+	Count           int     `json:"count"`
+	Score           float64 `json:"score,omitempty"` // Decayed or weighted votes, if decaying or weighted
+	PercentOfVoters float64 `json:"percentOfVoters"`
+	PercentOfVotes  float64 `json:"percentOfVotes"`
+}
+
 type Counts struct {
-	TokensAndCounts [][]any `json:"tokensAndCounts"` // Inner array is (string, int) pair
+	TokensAndCounts [][]any      `json:"tokensAndCounts"` // Inner array is (int, []string) pair, most votes first
+	Results         []Result     `json:"results"`         // Most votes first, then earliest to reach count
+	Voters          int          `json:"voters"`
+	Votes           int          `json:"votes"`
+	Rating          *RatingStats `json:"rating,omitempty"` // If a rating poll
+	Runoff          *Runoff      `json:"runoff,omitempty"` // If instant-runoff voting
+	State           State        `json:"state"`
+	Hidden          bool         `json:"hidden,omitempty"` // Results are hidden until revealed
+}
+
+type Snapshot struct {
+	Time   time.Time `json:"ts"`
+	Counts Counts    `json:"counts"`
+}
+
+type multiSetNode[T comparable] struct {
+	element    T
+	bucket     *multiSetBucket[T]
+	prev, next *multiSetNode[T]
+}
+
+// Elements with the same count, in the order they reached the count.
+type multiSetBucket[T comparable] struct {
+	count         int
+	first, last   *multiSetNode[T]
+	higher, lower *multiSetBucket[T]
+}
+
+func (b *multiSetBucket[T]) empty() bool {
+	return b.first == nil
+}
+
+func (b *multiSetBucket[T]) remove(node *multiSetNode[T]) {
+	if node.prev != nil {
+		node.prev.next = node.next
+	} else {
+		b.first = node.next
+	}
+	if node.next != nil {
+		node.next.prev = node.prev
+	} else {
+		b.last = node.prev
+	}
+	node.prev, node.next, node.bucket = nil, nil, nil
+}
+
+func (b *multiSetBucket[T]) pushLast(node *multiSetNode[T]) {
+	node.bucket = b
+	node.prev = b.last
+	if b.last != nil {
+		b.last.next = node
+	} else {
+		b.first = node
+	}
+	b.last = node
 }
 
-func (c *Counts) MarshalJSON() ([]byte, error) {
-	return json.Marshal(c)
+func (b *multiSetBucket[T]) pushFirst(node *multiSetNode[T]) {
+	node.bucket = b
+	node.next = b.first
+	if b.first != nil {
+		b.first.prev = node
+	} else {
+		b.last = node
+	}
+	b.first = node
 }
 
+// Counts elements, keeping them ranked by count. Buckets of equal counts form
+// a list ordered by count, so that incrementing or decrementing a count by one
+// is O(1), and ranked iteration is O(n).
 // Non-threadsafe - only share copies!
 type multiSet[T comparable] struct {
-	countsByElement map[T]int
-	elementsByCount map[int][]T
+	nodesByElement map[T]*multiSetNode[T]
+	highest        *multiSetBucket[T]
+	lowest         *multiSetBucket[T]
+}
+
+func (f *multiSet[T]) unlinkBucket(bucket *multiSetBucket[T]) {
+	if bucket.higher != nil {
+		bucket.higher.lower = bucket.lower
+	} else {
+		f.highest = bucket.lower
+	}
+	if bucket.lower != nil {
+		bucket.lower.higher = bucket.higher
+	} else {
+		f.lowest = bucket.higher
+	}
+}
+
+// Finds or creates the bucket for count, searching from start (nil searches
+// from the lowest bucket).
+func (f *multiSet[T]) bucketFor(count int, start *multiSetBucket[T]) *multiSetBucket[T] {
+	// Find the highest bucket with count at most count - the new bucket goes above it
+	below := start
+	if below == nil {
+		below = f.lowest
+		if below != nil && below.count > count {
+			below = nil
+		}
+	}
+	for below != nil && below.count > count {
+		below = below.lower
+	}
+	for below != nil && below.higher != nil && below.higher.count <= count {
+		below = below.higher
+	}
+	if below != nil && below.count == count {
+		return below
+	}
+
+	bucket := &multiSetBucket[T]{count: count, lower: below}
+	if below != nil {
+		bucket.higher = below.higher
+		below.higher = bucket
+	} else {
+		bucket.higher = f.lowest
+		f.lowest = bucket
+	}
+	if bucket.higher != nil {
+		bucket.higher.lower = bucket
+	} else {
+		f.highest = bucket
+	}
+
+	return bucket
+}
+
+func (f *multiSet[T]) count(element T) int {
+	if node, ok := f.nodesByElement[element]; ok {
+		return node.bucket.count
+	}
+
+	return 0
 }
 
 // Mutates state
@@ -24,34 +159,53 @@ func (f *multiSet[T]) update(element T, delta int) {
 		return
 	}
 
-	oldCount := f.countsByElement[element]
+	node, exists := f.nodesByElement[element]
+	var oldBucket *multiSetBucket[T]
+	oldCount := 0
+	if exists {
+		oldBucket = node.bucket
+		oldCount = oldBucket.count
+		oldBucket.remove(node)
+	} else {
+		node = &multiSetNode[T]{element: element}
+	}
 	newCount := oldCount + delta
 
 	if newCount > 0 {
-		f.countsByElement[element] = newCount
-
-		// Add to new count elements
+		f.nodesByElement[element] = node
+		newBucket := f.bucketFor(newCount, oldBucket)
 		if delta > 0 {
-			f.elementsByCount[newCount] = append(f.elementsByCount[newCount], element)
+			newBucket.pushLast(node)
 		} else {
-			f.elementsByCount[newCount] = append([]T{element}, f.elementsByCount[newCount]...)
+			newBucket.pushFirst(node)
 		}
 	} else {
-		delete(f.countsByElement, element)
+		delete(f.nodesByElement, element)
 	}
-	// Remove from old count elements
-	oldCountElems := make([]T, 0, len(f.elementsByCount[oldCount]))
-	for _, oldCountItem := range f.elementsByCount[oldCount] {
-		if element != oldCountItem {
-			oldCountElems = append(oldCountElems, oldCountItem)
+	if oldBucket != nil && oldBucket.empty() {
+		f.unlinkBucket(oldBucket)
+	}
+}
+
+type elementCount[T comparable] struct {
+	element T
+	count   int
+}
+
+// Highest count first. Equal counts are ordered by when they reached the count.
+func (f *multiSet[T]) ranked() []elementCount[T] {
+	ranked := make([]elementCount[T], 0, len(f.nodesByElement))
+	for bucket := f.highest; bucket != nil; bucket = bucket.lower {
+		for node := bucket.first; node != nil; node = node.next {
+			ranked = append(ranked, elementCount[T]{element: node.element, count: bucket.count})
 		}
 	}
-	f.elementsByCount[oldCount] = oldCountElems
+
+	return ranked
 }
 
 func newMultiSet[T comparable](initialCapacity int) multiSet[T] {
 	return multiSet[T]{
-		countsByElement: make(map[T]int, initialCapacity),
-		elementsByCount: make(map[int][]T, initialCapacity),
+		nodesByElement: make(map[T]*multiSetNode[T], initialCapacity),
 	}
 }
@@ -0,0 +1,347 @@
+package counter
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"presentation-service/internal/chat"
+	"presentation-service/internal/token"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// The map of slices multiSet, before buckets were linked.
+type sliceMultiSet struct {
+	countsByElement map[string]int
+	elementsByCount map[int][]string
+}
+
+func (f *sliceMultiSet) update(element string, delta int) {
+	if delta == 0 {
+		return
+	}
+	oldCount := f.countsByElement[element]
+	newCount := oldCount + delta
+	if newCount > 0 {
+		f.countsByElement[element] = newCount
+		if delta > 0 {
+			f.elementsByCount[newCount] = append(f.elementsByCount[newCount], element)
+		} else {
+			f.elementsByCount[newCount] = append([]string{element}, f.elementsByCount[newCount]...)
+		}
+	} else {
+		delete(f.countsByElement, element)
+	}
+	remaining := make([]string, 0, len(f.elementsByCount[oldCount]))
+	for _, other := range f.elementsByCount[oldCount] {
+		if other != element {
+			remaining = append(remaining, other)
+		}
+	}
+	f.elementsByCount[oldCount] = remaining
+}
+
+func (f *sliceMultiSet) ranked() []elementCount[string] {
+	highest := 0
+	for _, count := range f.countsByElement {
+		if count > highest {
+			highest = count
+		}
+	}
+	ranked := make([]elementCount[string], 0, len(f.countsByElement))
+	for count := highest; count > 0; count-- {
+		for _, element := range f.elementsByCount[count] {
+			ranked = append(ranked, elementCount[string]{element: element, count: count})
+		}
+	}
+
+	return ranked
+}
+
+// Fails unless buckets are non-empty, linked both ways, and strictly ordered.
+func checkBuckets(t *testing.T, f *multiSet[string]) {
+	t.Helper()
+	numNodes := 0
+	var higher *multiSetBucket[string]
+	for bucket := f.highest; bucket != nil; bucket = bucket.lower {
+		if bucket.empty() {
+			t.Fatalf("bucket %d is empty", bucket.count)
+		}
+		if bucket.higher != higher {
+			t.Fatalf("bucket %d is not linked to the bucket above it", bucket.count)
+		}
+		if higher != nil && higher.count <= bucket.count {
+			t.Fatalf("bucket %d is below bucket %d", higher.count, bucket.count)
+		}
+		for node := bucket.first; node != nil; node = node.next {
+			if node.bucket != bucket {
+				t.Fatalf("%s is not in bucket %d", node.element, bucket.count)
+			}
+			if f.nodesByElement[node.element] != node {
+				t.Fatalf("%s is not indexed", node.element)
+			}
+			numNodes++
+		}
+		higher = bucket
+	}
+	if f.lowest != higher {
+		t.Fatal("lowest bucket is not the last bucket")
+	}
+	if numNodes != len(f.nodesByElement) {
+		t.Fatalf("%d elements in buckets, want %d", numNodes, len(f.nodesByElement))
+	}
+}
+
+func bucketCounts(f *multiSet[string]) []int {
+	var counts []int
+	for bucket := f.highest; bucket != nil; bucket = bucket.lower {
+		counts = append(counts, bucket.count)
+	}
+
+	return counts
+}
+
+func TestMultiSetIncrement(t *testing.T) {
+	f := newMultiSet[string](0)
+	f.update("a", 1)
+	f.update("b", 1)
+	f.update("a", 1)
+	f.update("c", 1)
+	checkBuckets(t, &f)
+
+	want := []elementCount[string]{{"a", 2}, {"b", 1}, {"c", 1}}
+	if ranked := f.ranked(); !reflect.DeepEqual(ranked, want) {
+		t.Errorf("ranked %v, want %v", ranked, want)
+	}
+	if count := f.count("a"); count != 2 {
+		t.Errorf("count %d, want 2", count)
+	}
+	if count := f.count("d"); count != 0 {
+		t.Errorf("count %d, want 0", count)
+	}
+}
+
+func TestMultiSetDecrementToZeroUnlinksBucket(t *testing.T) {
+	f := newMultiSet[string](0)
+	f.update("a", 1)
+	f.update("b", 1)
+	f.update("b", 1)
+	f.update("c", 1)
+	f.update("c", 1)
+	f.update("c", 1)
+
+	f.update("a", -1)
+	checkBuckets(t, &f)
+	if counts := bucketCounts(&f); !reflect.DeepEqual(counts, []int{3, 2}) {
+		t.Errorf("buckets %v, want [3 2]", counts)
+	}
+	if _, ok := f.nodesByElement["a"]; ok {
+		t.Error("a is indexed, want removed")
+	}
+
+	f.update("c", -3)
+	f.update("b", -2)
+	checkBuckets(t, &f)
+	if f.highest != nil || f.lowest != nil || len(f.nodesByElement) != 0 {
+		t.Error("buckets remain, want empty")
+	}
+
+	f.update("a", -1)
+	checkBuckets(t, &f)
+	if len(f.nodesByElement) != 0 {
+		t.Error("a is counted, want counts below one ignored")
+	}
+}
+
+func TestMultiSetRankedTieOrder(t *testing.T) {
+	f := newMultiSet[string](0)
+	f.update("a", 1)
+	f.update("b", 1)
+	f.update("c", 1)
+	f.update("a", 1)
+	f.update("b", 1)
+	f.update("c", 1)
+	// Decremented elements go before others reaching the same count on the way up
+	f.update("b", -1)
+	f.update("a", -1)
+	checkBuckets(t, &f)
+
+	want := []elementCount[string]{{"c", 2}, {"a", 1}, {"b", 1}}
+	if ranked := f.ranked(); !reflect.DeepEqual(ranked, want) {
+		t.Errorf("ranked %v, want %v", ranked, want)
+	}
+}
+
+func TestMultiSetBucketForNonAdjacentDeltas(t *testing.T) {
+	f := newMultiSet[string](0)
+	f.update("a", 5)
+	f.update("b", 2)
+	f.update("c", 9)
+	f.update("d", 3)
+	checkBuckets(t, &f)
+	if counts := bucketCounts(&f); !reflect.DeepEqual(counts, []int{9, 5, 3, 2}) {
+		t.Errorf("buckets %v, want [9 5 3 2]", counts)
+	}
+
+	// Down past several buckets, and up past several buckets
+	f.update("c", -6)
+	f.update("b", 5)
+	checkBuckets(t, &f)
+	want := []elementCount[string]{{"b", 7}, {"a", 5}, {"c", 3}, {"d", 3}}
+	if ranked := f.ranked(); !reflect.DeepEqual(ranked, want) {
+		t.Errorf("ranked %v, want %v", ranked, want)
+	}
+
+	// Searching from the lowest bucket finds an existing bucket
+	if bucket := f.bucketFor(5, nil); bucket.count != 5 || bucket.first.element != "a" {
+		t.Errorf("bucket %d, want the existing bucket 5", bucket.count)
+	}
+	// Searching from a higher bucket creates a bucket below it
+	if bucket := f.bucketFor(4, f.highest); bucket.lower == nil || bucket.lower.count != 3 ||
+		bucket.higher == nil || bucket.higher.count != 5 {
+		t.Error("bucket 4 is not between buckets 3 and 5")
+	}
+}
+
+func TestMultiSetMatchesSliceMultiSet(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	f := newMultiSet[string](0)
+	reference := sliceMultiSet{countsByElement: map[string]int{}, elementsByCount: map[int][]string{}}
+	for i := 0; i < 10000; i++ {
+		element := fmt.Sprintf("t%d", random.Intn(20))
+		delta := random.Intn(7) - 3
+		if delta < 0 && -delta > reference.countsByElement[element] {
+			// Counts never go below zero in polls
+			delta = -reference.countsByElement[element]
+		}
+		f.update(element, delta)
+		reference.update(element, delta)
+		if i%100 == 0 {
+			checkBuckets(t, &f)
+		}
+	}
+	checkBuckets(t, &f)
+
+	if ranked, want := f.ranked(), reference.ranked(); !reflect.DeepEqual(ranked, want) {
+		t.Errorf("ranked %v, want %v", ranked, want)
+	}
+}
+
+// Implemented by multiSet and sliceMultiSet, to benchmark one against the other.
+type rankedCounter interface {
+	update(element string, delta int)
+	ranked() []elementCount[string]
+}
+
+var rankedCounters = []struct {
+	name       string
+	newCounter func(initialCapacity int) rankedCounter
+}{
+	{name: "multiSet", newCounter: func(initialCapacity int) rankedCounter {
+		f := newMultiSet[string](initialCapacity)
+		return &f
+	}},
+	{name: "sliceMultiSet", newCounter: func(initialCapacity int) rankedCounter {
+		return &sliceMultiSet{
+			countsByElement: make(map[string]int, initialCapacity),
+			elementsByCount: map[int][]string{},
+		}
+	}},
+}
+
+// Skewed towards low numbered tokens, like real polls.
+func randomToken(random *rand.Rand, numTokens int) string {
+	return fmt.Sprintf("t%d", int(random.ExpFloat64()*float64(numTokens)/8)%numTokens)
+}
+
+func BenchmarkMultiSetUpdate(b *testing.B) {
+	const numElements = 5000
+	for _, implementation := range rankedCounters {
+		b.Run(implementation.name, func(b *testing.B) {
+			random := rand.New(rand.NewSource(1))
+			elements := make([]string, b.N)
+			for i := range elements {
+				elements[i] = randomToken(random, numElements)
+			}
+			f := implementation.newCounter(numElements)
+			b.ResetTimer()
+			for i, element := range elements {
+				f.update(element, 1)
+				if i%3 == 0 {
+					f.update(element, -1)
+				}
+			}
+		})
+	}
+}
+
+const numStormSenders, numStormTokens, stormTokensPerSender = 20000, 5000, 3
+
+func voteStormMessages() []chat.Message {
+	random := rand.New(rand.NewSource(1))
+	messages := make([]chat.Message, numStormSenders)
+	for i := range messages {
+		messages[i] = chat.Message{
+			Sender: fmt.Sprintf("sender-%d", i),
+			Text:   randomToken(random, numStormTokens) + " " + randomToken(random, numStormTokens),
+		}
+	}
+
+	return messages
+}
+
+// Each sender's votes as the counter tracks them, keeping the most recent
+// stormTokensPerSender tokens, and ranking as often as scores are notified.
+func BenchmarkVoteStorm(b *testing.B) {
+	messages := voteStormMessages()
+	for _, implementation := range rankedCounters {
+		b.Run(implementation.name, func(b *testing.B) {
+			tokensBySender := make(map[string][]string, numStormSenders)
+			f := implementation.newCounter(numStormTokens)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				message := messages[i%numStormSenders]
+				tokens := tokensBySender[message.Sender]
+			words:
+				for _, word := range strings.Fields(message.Text) {
+					for _, token := range tokens {
+						if token == word {
+							continue words
+						}
+					}
+					if len(tokens) == stormTokensPerSender {
+						f.update(tokens[0], -1)
+						tokens = tokens[1:]
+					}
+					tokens = append(tokens, word)
+					f.update(word, 1)
+				}
+				tokensBySender[message.Sender] = tokens
+				if i%100 == 0 {
+					f.ranked()
+				}
+			}
+		})
+	}
+
+	// End to end, with the multiSet
+	b.Run("SendersByTokenCounter", func(b *testing.B) {
+		defer log.SetOutput(log.Writer())
+		log.SetOutput(io.Discard)
+		vocabulary := make(map[string]string, numStormTokens)
+		for i := 0; i < numStormTokens; i++ {
+			alias := fmt.Sprintf("t%d", i)
+			vocabulary[alias] = alias
+		}
+		pollCounter := NewSendersByTokenActor(
+			"vote-storm", stormTokensPerSender, 0, Counting{}, Lifecycle{}, nil, Voting{},
+			token.NewVocabularyExtractor(vocabulary), numStormTokens,
+		)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			pollCounter.NewMessage(messages[i%numStormSenders])
+		}
+	})
+}
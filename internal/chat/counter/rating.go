@@ -0,0 +1,56 @@
+package counter
+
+import (
+	"presentation-service/internal/token"
+	"strconv"
+)
+
+type RatingCount struct {
+	Rating int `json:"rating"`
+	Count  int `json:"count"`
+}
+
+type RatingStats struct {
+	Mean      float64       `json:"mean"`
+	Median    float64       `json:"median"`
+	Histogram []RatingCount `json:"histogram"` // Every rating on the scale, lowest first
+}
+
+func newRatingStats(scale token.RatingScale, ranked []elementCount[string]) *RatingStats {
+	stats := &RatingStats{Histogram: make([]RatingCount, 0, scale.Max-scale.Min+1)}
+	for rating := scale.Min; rating <= scale.Max; rating++ {
+		stats.Histogram = append(stats.Histogram, RatingCount{Rating: rating})
+	}
+	total, sum := 0, 0
+	for _, tokenCount := range ranked {
+		rating, err := strconv.Atoi(tokenCount.element)
+		if err != nil || !scale.Contains(rating) {
+			continue
+		}
+		stats.Histogram[rating-scale.Min].Count += tokenCount.count
+		total += tokenCount.count
+		sum += rating * tokenCount.count
+	}
+	if total == 0 {
+		return stats
+	}
+	stats.Mean = float64(sum) / float64(total)
+
+	// Average of the middle two ratings if there are an even number
+	lowerMiddle, upperMiddle := (total-1)/2, total/2
+	seen := 0
+	lower, upper := -1, -1
+	for _, ratingCount := range stats.Histogram {
+		seen += ratingCount.Count
+		if lower < 0 && seen > lowerMiddle {
+			lower = ratingCount.Rating
+		}
+		if seen > upperMiddle {
+			upper = ratingCount.Rating
+			break
+		}
+	}
+	stats.Median = float64(lower+upper) / 2
+
+	return stats
+}
@@ -0,0 +1,60 @@
+package counter
+
+import (
+	"presentation-service/internal/token"
+	"reflect"
+	"testing"
+)
+
+func TestRatingStats(t *testing.T) {
+	for _, test := range []struct {
+		name       string
+		ranked     []elementCount[string]
+		wantMean   float64
+		wantMedian float64
+	}{
+		{name: "no ratings"},
+		{name: "one rating", ranked: []elementCount[string]{{"4", 1}}, wantMean: 4, wantMedian: 4},
+		{
+			name:     "odd number of ratings",
+			ranked:   []elementCount[string]{{"5", 2}, {"1", 1}},
+			wantMean: 11.0 / 3, wantMedian: 5,
+		},
+		{
+			name:     "even number of ratings averages the middle two",
+			ranked:   []elementCount[string]{{"2", 1}, {"3", 1}, {"4", 1}, {"5", 1}},
+			wantMean: 3.5, wantMedian: 3.5,
+		},
+		{
+			name:     "middle two in different buckets",
+			ranked:   []elementCount[string]{{"1", 2}, {"4", 2}},
+			wantMean: 2.5, wantMedian: 2.5,
+		},
+		{
+			name:     "middle two in the same bucket",
+			ranked:   []elementCount[string]{{"3", 3}, {"1", 1}},
+			wantMean: 2.5, wantMedian: 3,
+		},
+		{
+			name:     "other tokens ignored",
+			ranked:   []elementCount[string]{{"2", 1}, {OtherToken, 5}, {"9", 1}},
+			wantMean: 2, wantMedian: 2,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			stats := newRatingStats(token.DefaultRatingScale, test.ranked)
+			if stats.Mean != test.wantMean || stats.Median != test.wantMedian {
+				t.Errorf("mean %v, median %v, want mean %v, median %v", stats.Mean, stats.Median, test.wantMean, test.wantMedian)
+			}
+		})
+	}
+}
+
+func TestRatingStatsHistogram(t *testing.T) {
+	stats := newRatingStats(token.RatingScale{Min: 0, Max: 3}, []elementCount[string]{{"2", 3}, {"0", 1}})
+
+	want := []RatingCount{{Rating: 0, Count: 1}, {Rating: 1}, {Rating: 2, Count: 3}, {Rating: 3}}
+	if !reflect.DeepEqual(stats.Histogram, want) {
+		t.Errorf("histogram %v, want %v", stats.Histogram, want)
+	}
+}
@@ -0,0 +1,129 @@
+package counter
+
+import (
+	"sort"
+)
+
+type VotingMode string
+
+const (
+	// Every token counts the same
+	VotingPlurality VotingMode = "plurality"
+	// Tokens are scored by their preference in each sender's ranking
+	VotingWeighted VotingMode = "weighted"
+	// Also tally each sender's ranking by instant-runoff
+	VotingInstantRunoff VotingMode = "instant-runoff"
+)
+
+func (m VotingMode) Valid() bool {
+	return m == VotingPlurality || m == VotingWeighted || m == VotingInstantRunoff
+}
+
+// A sender's ranking is the order of their most recent tokens, prioritizing
+// the first token of each message. Each message without a sender is ranked
+// as a separate sender.
+type Voting struct {
+	Mode VotingMode
+	// By preference, if weighted. Preferences beyond the last weight have the
+	// last weight. Defaults to a Borda count - tokensPerSender for the first
+	// preference, down to 1 for the last - so that every ranked token scores,
+	// but each preference outweighs the one after it.
+	Weights []float64
+}
+
+func (v Voting) ranked() bool {
+	return v.Mode == VotingWeighted || v.Mode == VotingInstantRunoff
+}
+
+func (v Voting) weight(preference int) float64 {
+	if preference >= len(v.Weights) {
+		preference = len(v.Weights) - 1
+	}
+
+	return v.Weights[preference]
+}
+
+type Tally struct {
+	Token string `json:"token"`
+	Votes int    `json:"votes"`
+}
+
+type RunoffRound struct {
+	Tallies    []Tally  `json:"tallies"`   // Most votes first
+	Exhausted  int      `json:"exhausted"` // Ballots without a remaining preference
+	Eliminated []string `json:"eliminated,omitempty"`
+}
+
+type Runoff struct {
+	Rounds []RunoffRound `json:"rounds"`
+	Winner string        `json:"winner,omitempty"` // Empty if there are no votes, or an exact tie
+}
+
+// Each round counts every ballot towards its most preferred remaining token,
+// until a token has a majority of the ballots that aren't exhausted. Otherwise
+// the tokens with the fewest votes are eliminated together. If that would
+// eliminate every remaining token, they tie exactly, and the last round has no
+// winner - the tie is left to the presenter, rather than broken arbitrarily.
+func instantRunoff(ballots [][]string) *Runoff {
+	runoff := &Runoff{Rounds: []RunoffRound{}}
+	remaining := map[string]struct{}{}
+	for _, ballot := range ballots {
+		for _, token := range ballot {
+			remaining[token] = struct{}{}
+		}
+	}
+
+	for len(remaining) > 0 {
+		votesByToken := make(map[string]int, len(remaining))
+		for token := range remaining {
+			votesByToken[token] = 0
+		}
+		round := RunoffRound{Tallies: make([]Tally, 0, len(remaining))}
+		for _, ballot := range ballots {
+			exhausted := true
+			for _, token := range ballot {
+				if _, ok := remaining[token]; ok {
+					votesByToken[token]++
+					exhausted = false
+					break
+				}
+			}
+			if exhausted {
+				round.Exhausted++
+			}
+		}
+		for token, votes := range votesByToken {
+			round.Tallies = append(round.Tallies, Tally{Token: token, Votes: votes})
+		}
+		sort.Slice(round.Tallies, func(i, j int) bool {
+			if round.Tallies[i].Votes != round.Tallies[j].Votes {
+				return round.Tallies[i].Votes > round.Tallies[j].Votes
+			}
+			return round.Tallies[i].Token < round.Tallies[j].Token
+		})
+
+		leader := round.Tallies[0]
+		if 2*leader.Votes > len(ballots)-round.Exhausted {
+			runoff.Rounds = append(runoff.Rounds, round)
+			runoff.Winner = leader.Token
+			break
+		}
+		fewestVotes := round.Tallies[len(round.Tallies)-1].Votes
+		for _, tally := range round.Tallies {
+			if tally.Votes == fewestVotes {
+				round.Eliminated = append(round.Eliminated, tally.Token)
+			}
+		}
+		if len(round.Eliminated) == len(round.Tallies) {
+			round.Eliminated = nil
+			runoff.Rounds = append(runoff.Rounds, round)
+			break
+		}
+		for _, token := range round.Eliminated {
+			delete(remaining, token)
+		}
+		runoff.Rounds = append(runoff.Rounds, round)
+	}
+
+	return runoff
+}
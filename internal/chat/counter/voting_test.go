@@ -0,0 +1,143 @@
+package counter
+
+import (
+	"presentation-service/internal/chat"
+	"presentation-service/internal/token"
+	"reflect"
+	"testing"
+)
+
+func TestInstantRunoff(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		ballots [][]string
+		want    Runoff
+	}{
+		{
+			name: "no ballots",
+			want: Runoff{Rounds: []RunoffRound{}},
+		},
+		{
+			name:    "first round majority",
+			ballots: [][]string{{"A"}, {"A", "B"}, {"B"}},
+			want: Runoff{
+				Rounds: []RunoffRound{{Tallies: []Tally{{"A", 2}, {"B", 1}}}},
+				Winner: "A",
+			},
+		},
+		{
+			name:    "lower preferences tallied without votes",
+			ballots: [][]string{{"A", "B"}, {"A", "B"}, {"C"}},
+			want: Runoff{
+				Rounds: []RunoffRound{{Tallies: []Tally{{"A", 2}, {"C", 1}, {"B", 0}}}},
+				Winner: "A",
+			},
+		},
+		{
+			name:    "eliminated votes transfer to the next preference",
+			ballots: [][]string{{"A"}, {"A"}, {"B", "C"}, {"C"}, {"C"}},
+			want: Runoff{
+				Rounds: []RunoffRound{
+					{Tallies: []Tally{{"A", 2}, {"C", 2}, {"B", 1}}, Eliminated: []string{"B"}},
+					{Tallies: []Tally{{"C", 3}, {"A", 2}}},
+				},
+				Winner: "C",
+			},
+		},
+		{
+			name: "several rounds",
+			ballots: [][]string{
+				{"A"}, {"A"}, {"A"}, {"A"}, {"B", "C"}, {"B", "C"}, {"B", "C"}, {"C", "B"}, {"C", "B"}, {"D", "B"},
+			},
+			want: Runoff{
+				Rounds: []RunoffRound{
+					{Tallies: []Tally{{"A", 4}, {"B", 3}, {"C", 2}, {"D", 1}}, Eliminated: []string{"D"}},
+					{Tallies: []Tally{{"A", 4}, {"B", 4}, {"C", 2}}, Eliminated: []string{"C"}},
+					{Tallies: []Tally{{"B", 6}, {"A", 4}}},
+				},
+				Winner: "B",
+			},
+		},
+		{
+			name:    "tied fewest eliminated together, and exhausted ballots don't count towards a majority",
+			ballots: [][]string{{"A"}, {"A"}, {"A"}, {"B"}, {"B"}, {"C"}, {"D"}},
+			want: Runoff{
+				Rounds: []RunoffRound{
+					{Tallies: []Tally{{"A", 3}, {"B", 2}, {"C", 1}, {"D", 1}}, Eliminated: []string{"C", "D"}},
+					{Tallies: []Tally{{"A", 3}, {"B", 2}}, Exhausted: 2},
+				},
+				Winner: "A",
+			},
+		},
+		{
+			name:    "exact tie has no winner",
+			ballots: [][]string{{"A", "B"}, {"A"}, {"B"}, {"C", "B"}, {"C"}, {"B", "A"}},
+			want: Runoff{
+				Rounds: []RunoffRound{
+					{Tallies: []Tally{{"A", 2}, {"B", 2}, {"C", 2}}},
+				},
+			},
+		},
+		{
+			name:    "exact tie after elimination has no winner",
+			ballots: [][]string{{"A"}, {"A"}, {"B"}, {"B"}, {"C"}},
+			want: Runoff{
+				Rounds: []RunoffRound{
+					{Tallies: []Tally{{"A", 2}, {"B", 2}, {"C", 1}}, Eliminated: []string{"C"}},
+					{Tallies: []Tally{{"A", 2}, {"B", 2}}, Exhausted: 1},
+				},
+			},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			if runoff := instantRunoff(test.ballots); !reflect.DeepEqual(*runoff, test.want) {
+				t.Errorf("runoff %+v, want %+v", *runoff, test.want)
+			}
+		})
+	}
+}
+
+func TestWeight(t *testing.T) {
+	v := Voting{Mode: VotingWeighted, Weights: []float64{5, 3, 1}}
+	for preference, want := range []float64{5, 3, 1, 1, 1} {
+		if weight := v.weight(preference); weight != want {
+			t.Errorf("preference %d weighs %v, want %v", preference, weight, want)
+		}
+	}
+}
+
+func TestWeightedVoting(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		weights []float64
+		want    map[string]float64
+	}{
+		// Both rank Go first, since their latest message was "go". Defaults to 3, 2
+		// and 1 for 3 tokens per sender
+		{name: "default weights", want: map[string]float64{"Go": 6, "Rust": 4, "Java": 1}},
+		{name: "custom weights", weights: []float64{10, 1}, want: map[string]float64{"Go": 20, "Rust": 2, "Java": 1}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			c := NewSendersByTokenActor(
+				"language-poll", 3, 0, Counting{}, Lifecycle{}, nil, Voting{Mode: VotingWeighted, Weights: test.weights},
+				token.NewVocabularyExtractor(map[string]string{"go": "Go", "rust": "Rust", "java": "Java"}), 0,
+			)
+			for _, message := range []chat.Message{
+				{Sender: "Jane", Text: "go, rust then java"},
+				{Sender: "Bob", Text: "rust"},
+				{Sender: "Bob", Text: "go"},
+			} {
+				c.NewMessage(message)
+			}
+
+			counts := c.copyCounts()
+			scores := map[string]float64{}
+			for _, result := range counts.Results {
+				scores[result.Token] = result.Score
+			}
+			if !reflect.DeepEqual(scores, test.want) {
+				t.Errorf("scores %v, want %v", scores, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,197 @@
+package irc
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"net"
+	"presentation-service/internal/chat"
+	"strings"
+	"time"
+)
+
+const (
+	dialTimeout   = 10 * time.Second
+	writeTimeout  = 10 * time.Second
+	idleTimeout   = 2 * time.Minute // Before pinging the server, then disconnecting
+	minBackoff    = time.Second
+	maxBackoff    = 2 * time.Minute
+	ctcpDelimiter = "\x01"
+)
+
+var errNotResponding = errors.New("server not responding")
+
+type Options struct {
+	Addr     string // host:port
+	TLS      bool
+	Nick     string
+	Password string // Optional, e.g. a Twitch "oauth:..." token
+	Channel  string
+}
+
+// Ingests messages from an IRC channel (including Twitch chat), reconnecting
+// with exponential backoff.
+type Client struct {
+	options   Options
+	onMessage func(chat.Message)
+}
+
+func (c *Client) dialServer(ctx context.Context) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: dialTimeout}
+	if !c.options.TLS {
+		return dialer.DialContext(ctx, "tcp", c.options.Addr)
+	}
+	host, _, err := net.SplitHostPort(c.options.Addr)
+	if err != nil {
+		return nil, err
+	}
+
+	return (&tls.Dialer{NetDialer: dialer, Config: &tls.Config{ServerName: host}}).DialContext(
+		ctx, "tcp", c.options.Addr,
+	)
+}
+
+// Runs until ctx is cancelled.
+func (c *Client) Run(ctx context.Context) {
+	backoff := minBackoff
+	for {
+		registered, err := c.session(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if registered {
+			backoff = minBackoff
+		}
+		// Up to 50% jitter, so that restarted servers don't reconnect in lockstep
+		delay := backoff + time.Duration(rand.Int63n(int64(backoff/2)+1))
+		log.Printf("Disconnected from IRC %s (%v), reconnecting in %s", c.options.Addr, err, delay.Round(time.Millisecond))
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		if backoff *= 2; backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+// Connects, joins the channel and relays messages until disconnected,
+// reporting whether the client registered with the server.
+func (c *Client) session(ctx context.Context) (registered bool, err error) {
+	conn, err := c.dialServer(ctx)
+	if err != nil {
+		return false, err
+	}
+	sessionCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		<-sessionCtx.Done()
+		_ = conn.Close()
+	}()
+
+	send := func(format string, args ...any) error {
+		_ = conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+		_, writeErr := fmt.Fprintf(conn, format+"\r\n", args...)
+
+		return writeErr
+	}
+	if c.options.Password != "" {
+		if err = send("PASS %s", c.options.Password); err != nil {
+			return false, err
+		}
+	}
+	// Twitch tags carry display names, other servers ignore or refuse the request
+	if err = send("CAP REQ :twitch.tv/tags"); err != nil {
+		return false, err
+	}
+	if err = send("NICK %s", c.options.Nick); err != nil {
+		return false, err
+	}
+	if err = send("USER %s 0 * :%s", c.options.Nick, c.options.Nick); err != nil {
+		return false, err
+	}
+	if err = send("CAP END"); err != nil {
+		return false, err
+	}
+
+	reader := bufio.NewReader(conn)
+	pinged := false
+	for {
+		_ = conn.SetReadDeadline(time.Now().Add(idleTimeout))
+		raw, readErr := reader.ReadString('\n')
+		if readErr != nil {
+			var netErr net.Error
+			if errors.As(readErr, &netErr) && netErr.Timeout() && !pinged {
+				pinged = true
+				if err = send("PING :%s", c.options.Nick); err != nil {
+					return registered, err
+				}
+				continue
+			}
+			if pinged {
+				return registered, errNotResponding
+			}
+			return registered, readErr
+		}
+		pinged = false
+
+		parsed := parseLine(raw)
+		switch parsed.command {
+		case "PING":
+			err = send("PONG :%s", parsed.param(0))
+		case "001": // Welcome
+			registered = true
+			log.Printf("Connected to IRC %s, joining %s", c.options.Addr, c.options.Channel)
+			err = send("JOIN %s", c.options.Channel)
+		case "433": // Nick in use
+			return registered, fmt.Errorf("nick %s is in use", c.options.Nick)
+		case "ERROR":
+			return registered, fmt.Errorf("server error (%s)", parsed.param(0))
+		case "PRIVMSG":
+			if message, ok := c.chatMessage(parsed); ok {
+				c.onMessage(message)
+			}
+		}
+		if err != nil {
+			return registered, err
+		}
+	}
+}
+
+func (c *Client) chatMessage(privmsg line) (chat.Message, bool) {
+	target, text := privmsg.param(0), privmsg.param(1)
+	// CTCP requests and actions ("/me")
+	if strings.HasPrefix(text, ctcpDelimiter) {
+		return chat.Message{}, false
+	}
+
+	var recipient string
+	switch {
+	case strings.EqualFold(target, c.options.Channel):
+		recipient = chat.RecipientEveryone
+	case strings.EqualFold(target, c.options.Nick):
+		recipient = chat.RecipientYou
+	default:
+		return chat.Message{}, false
+	}
+	sender := privmsg.tags["display-name"]
+	if sender == "" {
+		sender = privmsg.nick()
+	}
+	// Messages without a sender are taken as the moderator's
+	if strings.TrimSpace(sender) == "" {
+		return chat.Message{}, false
+	}
+
+	return chat.Message{Sender: sender, Recipient: recipient, Text: text, Time: time.Now()}, true
+}
+
+func NewClient(options Options, onMessage func(chat.Message)) *Client {
+	return &Client{options: options, onMessage: onMessage}
+}
@@ -0,0 +1,197 @@
+package irc
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"presentation-service/internal/chat"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testWait = 5 * time.Second
+
+func TestMain(m *testing.M) {
+	log.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+// An IRC server the client under test connects to.
+type fakeServer struct {
+	listener net.Listener
+}
+
+type fakeConn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+func newFakeServer(t *testing.T) *fakeServer {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+
+	return &fakeServer{listener: listener}
+}
+
+func (s *fakeServer) accept(t *testing.T) *fakeConn {
+	t.Helper()
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, err := s.listener.Accept()
+		if err == nil {
+			accepted <- conn
+		}
+	}()
+	select {
+	case conn := <-accepted:
+		t.Cleanup(func() { _ = conn.Close() })
+		return &fakeConn{conn: conn, reader: bufio.NewReader(conn)}
+	case <-time.After(testWait):
+		t.Fatal("client did not connect")
+		return nil
+	}
+}
+
+func (c *fakeConn) expect(t *testing.T, want string) {
+	t.Helper()
+	_ = c.conn.SetReadDeadline(time.Now().Add(testWait))
+	raw, err := c.reader.ReadString('\n')
+	if err != nil {
+		t.Fatalf("expected %q (%v)", want, err)
+	}
+	if got := strings.TrimRight(raw, "\r\n"); got != want {
+		t.Fatalf("received %q, want %q", got, want)
+	}
+}
+
+func (c *fakeConn) send(t *testing.T, raw string) {
+	t.Helper()
+	if _, err := fmt.Fprintf(c.conn, "%s\r\n", raw); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Expects the client to register, and welcomes it.
+func (c *fakeConn) register(t *testing.T, options Options) {
+	t.Helper()
+	if options.Password != "" {
+		c.expect(t, "PASS "+options.Password)
+	}
+	c.expect(t, "CAP REQ :twitch.tv/tags")
+	c.expect(t, "NICK "+options.Nick)
+	c.expect(t, "USER "+options.Nick+" 0 * :"+options.Nick)
+	c.expect(t, "CAP END")
+	c.send(t, ":irc.example.com 001 "+options.Nick+" :Welcome")
+	c.expect(t, "JOIN "+options.Channel)
+}
+
+func runClient(t *testing.T, options Options) <-chan chat.Message {
+	t.Helper()
+	messages := make(chan chat.Message, 16)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	go func() {
+		defer close(done)
+		NewClient(options, func(message chat.Message) { messages <- message }).Run(ctx)
+	}()
+
+	return messages
+}
+
+func receive(t *testing.T, messages <-chan chat.Message) chat.Message {
+	t.Helper()
+	select {
+	case message := <-messages:
+		message.Time = time.Time{}
+		return message
+	case <-time.After(testWait):
+		t.Fatal("no message received")
+		return chat.Message{}
+	}
+}
+
+func TestClient(t *testing.T) {
+	server := newFakeServer(t)
+	options := Options{
+		Addr: server.listener.Addr().String(), Nick: "deckbot", Password: "oauth:secret", Channel: "#talk",
+	}
+	messages := runClient(t, options)
+	conn := server.accept(t)
+	conn.register(t, options)
+
+	conn.send(t, "PING :irc.example.com")
+	conn.expect(t, "PONG :irc.example.com")
+
+	conn.send(t, `@badge-info=;display-name=Jane\sDoe;user-id=1 :jane!jane@jane.tmi.twitch.tv PRIVMSG #talk :Go and Rust`)
+	if message, want := receive(t, messages), (chat.Message{
+		Sender: "Jane Doe", Recipient: chat.RecipientEveryone, Text: "Go and Rust",
+	}); message != want {
+		t.Errorf("message %+v, want %+v", message, want)
+	}
+
+	conn.send(t, ":john!john@example.com PRIVMSG deckbot :Can you share the slides?")
+	if message, want := receive(t, messages), (chat.Message{
+		Sender: "john", Recipient: chat.RecipientYou, Text: "Can you share the slides?",
+	}); message != want {
+		t.Errorf("message %+v, want %+v", message, want)
+	}
+
+	// Neither CTCP, messages to other channels, nor messages without a sender
+	// are chat
+	conn.send(t, ":john!john@example.com PRIVMSG #talk :\x01ACTION waves\x01")
+	conn.send(t, ":john!john@example.com PRIVMSG deckbot :\x01VERSION\x01")
+	conn.send(t, ":john!john@example.com PRIVMSG #other :Rust")
+	conn.send(t, ":john!john@example.com NOTICE #talk :Python")
+	conn.send(t, "PRIVMSG #talk :Elm")
+	conn.send(t, `@display-name=\s PRIVMSG #talk :Elm`)
+	conn.send(t, ":john!john@example.com PRIVMSG #TALK :Java")
+	if message, want := receive(t, messages), (chat.Message{
+		Sender: "john", Recipient: chat.RecipientEveryone, Text: "Java",
+	}); message != want {
+		t.Errorf("message %+v, want %+v", message, want)
+	}
+}
+
+func TestClientReconnects(t *testing.T) {
+	server := newFakeServer(t)
+	options := Options{Addr: server.listener.Addr().String(), Nick: "deckbot", Channel: "#talk"}
+	messages := runClient(t, options)
+
+	conn := server.accept(t)
+	conn.register(t, options)
+	_ = conn.conn.Close()
+
+	conn = server.accept(t)
+	conn.register(t, options)
+	conn.send(t, ":jane!jane@example.com PRIVMSG #talk :Go")
+	if message := receive(t, messages); message.Text != "Go" {
+		t.Errorf("message %+v, want Go", message)
+	}
+}
+
+func TestClientReconnectsAfterError(t *testing.T) {
+	server := newFakeServer(t)
+	options := Options{Addr: server.listener.Addr().String(), Nick: "deckbot", Channel: "#talk"}
+	runClient(t, options)
+
+	conn := server.accept(t)
+	conn.expect(t, "CAP REQ :twitch.tv/tags")
+	conn.expect(t, "NICK deckbot")
+	conn.send(t, ":irc.example.com 433 * deckbot :Nickname is already in use")
+
+	conn = server.accept(t)
+	conn.register(t, options)
+}
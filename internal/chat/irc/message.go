@@ -0,0 +1,82 @@
+package irc
+
+import "strings"
+
+// An IRC protocol line, with IRCv3 tags.
+type line struct {
+	tags    map[string]string
+	prefix  string
+	command string
+	params  []string
+}
+
+func parseLine(raw string) line {
+	var parsed line
+	raw = strings.TrimRight(raw, "\r\n")
+	if strings.HasPrefix(raw, "@") {
+		var tags string
+		tags, raw, _ = strings.Cut(raw[1:], " ")
+		parsed.tags = make(map[string]string)
+		for _, tag := range strings.Split(tags, ";") {
+			key, value, _ := strings.Cut(tag, "=")
+			parsed.tags[key] = unescapeTagValue(value)
+		}
+	}
+	raw = strings.TrimLeft(raw, " ")
+	if strings.HasPrefix(raw, ":") {
+		parsed.prefix, raw, _ = strings.Cut(raw[1:], " ")
+	}
+	raw, trailing, hasTrailing := strings.Cut(raw, " :")
+	fields := strings.Fields(raw)
+	if len(fields) > 0 {
+		parsed.command = strings.ToUpper(fields[0])
+		parsed.params = fields[1:]
+	}
+	if hasTrailing {
+		parsed.params = append(parsed.params, trailing)
+	}
+
+	return parsed
+}
+
+var tagValueEscapes = map[byte]byte{':': ';', 's': ' ', '\\': '\\', 'r': '\r', 'n': '\n'}
+
+// Other escaped characters are unescaped as themselves, and a trailing
+// backslash is dropped.
+func unescapeTagValue(value string) string {
+	if !strings.Contains(value, `\`) {
+		return value
+	}
+	var unescaped strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] != '\\' {
+			unescaped.WriteByte(value[i])
+			continue
+		}
+		if i++; i == len(value) {
+			break
+		}
+		if escaped, ok := tagValueEscapes[value[i]]; ok {
+			unescaped.WriteByte(escaped)
+		} else {
+			unescaped.WriteByte(value[i])
+		}
+	}
+
+	return unescaped.String()
+}
+
+// The nick in a "nick!user@host" prefix.
+func (l line) nick() string {
+	nick, _, _ := strings.Cut(l.prefix, "!")
+
+	return nick
+}
+
+func (l line) param(i int) string {
+	if i < len(l.params) {
+		return l.params[i]
+	}
+
+	return ""
+}
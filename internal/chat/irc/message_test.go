@@ -0,0 +1,78 @@
+package irc
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseLine(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want line
+	}{
+		{"PING :tmi.twitch.tv\r\n", line{command: "PING", params: []string{"tmi.twitch.tv"}}},
+		{
+			":irc.example.com 001 deckbot :Welcome, deckbot\r\n",
+			line{prefix: "irc.example.com", command: "001", params: []string{"deckbot", "Welcome, deckbot"}},
+		},
+		{
+			":jane!jane@example.com PRIVMSG #talk :Go and Rust :)\r\n",
+			line{prefix: "jane!jane@example.com", command: "PRIVMSG", params: []string{"#talk", "Go and Rust :)"}},
+		},
+		{
+			"@badge-info=;display-name=Jane\\sDoe;emotes= :jane!jane@jane.tmi.twitch.tv PRIVMSG #talk :Go\r\n",
+			line{
+				tags:    map[string]string{"badge-info": "", "display-name": "Jane Doe", "emotes": ""},
+				prefix:  "jane!jane@jane.tmi.twitch.tv",
+				command: "PRIVMSG",
+				params:  []string{"#talk", "Go"},
+			},
+		},
+		{"@flag :server NOTICE * :hi", line{
+			tags: map[string]string{"flag": ""}, prefix: "server", command: "NOTICE", params: []string{"*", "hi"},
+		}},
+		{"join  #talk", line{command: "JOIN", params: []string{"#talk"}}},
+		{"PRIVMSG #talk :", line{command: "PRIVMSG", params: []string{"#talk", ""}}},
+		{"", line{}},
+	}
+	for _, test := range tests {
+		if parsed := parseLine(test.raw); !reflect.DeepEqual(parsed, test.want) {
+			t.Errorf("parseLine(%q) = %+v, want %+v", test.raw, parsed, test.want)
+		}
+	}
+}
+
+func TestLineNickAndParam(t *testing.T) {
+	parsed := parseLine(":jane!jane@example.com PRIVMSG #talk :Go")
+	if nick := parsed.nick(); nick != "jane" {
+		t.Errorf("nick %q, want jane", nick)
+	}
+	if nick := parseLine(":irc.example.com NOTICE * :hi").nick(); nick != "irc.example.com" {
+		t.Errorf("nick %q, want the server name", nick)
+	}
+	if param := parsed.param(2); param != "" {
+		t.Errorf("param %q, want empty", param)
+	}
+}
+
+func TestUnescapeTagValue(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"Jane", "Jane"},
+		{`Jane\sDoe`, "Jane Doe"},
+		{`a\:b`, "a;b"},
+		{`back\\slash`, `back\slash`},
+		{`line\r\nbreak`, "line\r\nbreak"},
+		{`\b\o\l\d`, "bold"},
+		{`trailing\`, "trailing"},
+		{`\\s`, `\s`},
+		{"", ""},
+	}
+	for _, test := range tests {
+		if value := unescapeTagValue(test.value); value != test.want {
+			t.Errorf("unescapeTagValue(%q) = %q, want %q", test.value, value, test.want)
+		}
+	}
+}
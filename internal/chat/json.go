@@ -0,0 +1,52 @@
+package chat
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+type jsonMessage struct {
+	Sender    string `json:"sender"`
+	Recipient string `json:"recipient"` // "Everyone" (default) or "You"
+	Text      string `json:"text"`
+}
+
+// A message object, or an array of them, for platforms without an adapter.
+type JSONSource struct{}
+
+func (JSONSource) Messages(r *http.Request, now time.Time) ([]Message, error) {
+	body, err := readPayload(r)
+	if err != nil {
+		return nil, err
+	}
+	var payload json.RawMessage
+	if err = decodePayload(body, &payload); err != nil {
+		return nil, err
+	}
+	var payloadMessages []jsonMessage
+	if err := json.Unmarshal(payload, &payloadMessages); err != nil {
+		var payloadMessage jsonMessage
+		if err = json.Unmarshal(payload, &payloadMessage); err != nil {
+			return nil, fmt.Errorf("%w (%v)", ErrMalformedPayload, err)
+		}
+		payloadMessages = []jsonMessage{payloadMessage}
+	}
+
+	messages := make([]Message, 0, len(payloadMessages))
+	for _, payloadMessage := range payloadMessages {
+		recipient := payloadMessage.Recipient
+		if recipient == "" {
+			recipient = RecipientEveryone
+		}
+		if payloadMessage.Sender == "" || (recipient != RecipientEveryone && recipient != RecipientYou) {
+			return nil, fmt.Errorf("%w: requires a sender, and a recipient of Everyone or You", ErrMalformedPayload)
+		}
+		messages = append(messages, Message{
+			Sender: payloadMessage.Sender, Recipient: recipient, Text: payloadMessage.Text, Time: now,
+		})
+	}
+
+	return messages, nil
+}
@@ -1,9 +1,14 @@
 package chat
 
+import (
+	"time"
+)
+
 type Message struct {
-	Sender    string `json:"s"`
-	Recipient string `json:"r"`
-	Text      string `json:"t"`
+	Sender    string    `json:"s"`
+	Recipient string    `json:"r"`
+	Text      string    `json:"t"`
+	Time      time.Time `json:"ts"`
 }
 
 func (m Message) String() string {
@@ -1,94 +1,281 @@
 package moderation
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"log"
 	"presentation-service/internal/chat"
 	"presentation-service/internal/notification"
+	"regexp"
+	"strconv"
 	"sync"
+	"time"
 )
 
+var (
+	ErrQuestionNotFound = errors.New("question not found")
+	ErrAlreadyVoted     = errors.New("already voted")
+	ErrInvalidCommand   = errors.New("invalid command")
+)
+
+// Rejected messages kept for subscribers resuming with SubscribeRejected.
+const recentRejectedCapacity = 256
+
+var upvoteRegex = regexp.MustCompile(`^\s*\+1\s*#(\d+)\s*$`)
+
+// Polls and quizzes, which report whether they took a message.
+type Consumer interface {
+	NewMessage(message chat.Message) bool
+}
+
 type TextCollector struct {
 	name                       string
-	chatText                   []string
+	attribution                Attribution
+	questions                  []*question
+	questionsByID              map[int]*question
+	nextQuestionID             int
 	mutex                      sync.RWMutex
 	initialCapacity            int
-	messages                   chan chat.Message
-	chatMessageBroadcaster     *chat.Broadcaster
+	consumers                  []Consumer
 	rejectedMessageBroadcaster *chat.Broadcaster
-	notification               *notification.Notification[Messages]
+	notification               *notification.SequencedNotification[Messages]
+	moderatorNotification      *notification.SequencedNotification[Messages]
+	rejectedNotification       *notification.SequencedNotification[RejectedMessage]
 }
 
-func (t *TextCollector) copyMessages() Messages {
+// Moderators see every question, with full sender names.
+func (t *TextCollector) copyMessages(moderator bool) Messages {
 	messages := Messages{
-		ChatText: make([]string, 0, len(t.chatText)),
+		ChatText:  make([]string, 0, len(t.questions)),
+		Questions: make([]Question, 0, len(t.questions)),
 	}
-	for i := 0; i < len(t.chatText); i++ {
-		messages.ChatText = append(messages.ChatText, t.chatText[i])
+	for _, q := range t.questions {
+		if moderator || q.state.visible() {
+			sender := q.sender
+			if !moderator {
+				sender = t.attribution.label(q.sender)
+			}
+			messages.Questions = append(messages.Questions, Question{
+				ID:     q.id,
+				Text:   q.text,
+				Sender: sender,
+				Time:   q.time,
+				Votes:  len(q.voters),
+				State:  q.state,
+			})
+		}
+	}
+	rankQuestions(messages.Questions)
+	for _, q := range messages.Questions {
+		if q.State.visible() {
+			messages.ChatText = append(messages.ChatText, q.Text)
+		}
 	}
 
 	return messages
 }
 
 func (t *TextCollector) notifyAllSubscribers() {
-	t.notification.NotifyAll(t.copyMessages())
+	t.notification.NotifyAll(t.copyMessages(false))
+	t.moderatorNotification.NotifyAll(t.copyMessages(true))
+}
+
+// Caller must hold mutex
+func (t *TextCollector) addQuestion(message chat.Message, state State) int {
+	q := &question{
+		id:     t.nextQuestionID,
+		text:   message.Text,
+		sender: message.Sender,
+		time:   message.Time,
+		state:  state,
+		voters: map[string]struct{}{},
+	}
+	if q.time.IsZero() {
+		q.time = time.Now()
+	}
+	t.nextQuestionID++
+	t.questions = append(t.questions, q)
+	t.questionsByID[q.id] = q
+	t.notifyAllSubscribers()
+
+	return q.id
 }
 
+// Audience messages may upvote questions (e.g. "+1 #3"). Otherwise, messages
+// are offered to every poll and quiz, and only if none of them take it, become
+// a question. Messages without a sender are from the moderator, and are
+// approved immediately. Audience questions are pending moderator approval,
+// retaining their sender, and are rejected - sent to moderators - once.
 func (t *TextCollector) NewMessage(message chat.Message) {
 	if message.Sender != "" {
-		t.rejectedMessageBroadcaster.NewMessage(message)
+		if match := upvoteRegex.FindStringSubmatch(message.Text); match != nil {
+			id, _ := strconv.Atoi(match[1])
+			if err := t.Upvote(id, message.Sender); err != nil {
+				log.Printf("Not upvoting %s question #%d (%v)", t.name, id, err)
+			}
+			return
+		}
+	}
+	taken := false
+	for _, consumer := range t.consumers {
+		if consumer.NewMessage(message) {
+			taken = true
+		}
+	}
+	if taken {
 		return
 	}
 
+	if message.Sender == "" {
+		t.mutex.Lock()
+		defer t.mutex.Unlock()
+		t.addQuestion(message, StateApproved)
+		return
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	id := t.addQuestion(message, StatePending)
+	t.rejectedNotification.NotifyAll(RejectedMessage{ID: id, Message: message})
+	t.rejectedMessageBroadcaster.NewMessage(message)
+}
+
+// Each sender may only vote once per question, and only for questions
+// shown on the presentation.
+func (t *TextCollector) Upvote(id int, sender string) error {
 	t.mutex.Lock()
 	defer t.mutex.Unlock()
-	t.chatText = append(t.chatText, message.Text)
+	q, ok := t.questionsByID[id]
+	if !ok || !q.state.visible() {
+		return ErrQuestionNotFound
+	}
+	if !q.vote(sender) {
+		return ErrAlreadyVoted
+	}
+	log.Printf("Upvoted %s question #%d (=%d)", t.name, id, len(q.voters))
 	t.notifyAllSubscribers()
+
+	return nil
 }
 
-func (t *TextCollector) Subscribe(subscriber chan<- Messages) {
-	go func() {
-		t.mutex.RLock()
-		defer t.mutex.RUnlock()
-		subscriber <- t.copyMessages()
-	}()
-	if t.messages == nil {
-		t.messages = make(chan chat.Message)
-		t.chatMessageBroadcaster.Subscribe(t.messages)
-		go func() {
-			for msg := range t.messages {
-				t.NewMessage(msg)
+func (t *TextCollector) Execute(command Command) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	q, ok := t.questionsByID[command.ID]
+	if !ok {
+		return ErrQuestionNotFound
+	}
+
+	switch command.Action {
+	case ActionApprove:
+		q.state = StateApproved
+	case ActionReject:
+		q.state = StateHidden
+	case ActionAnswer:
+		if !q.state.visible() {
+			return fmt.Errorf("%w: question #%d is %s", ErrInvalidCommand, q.id, q.state)
+		}
+		q.state = StateAnswered
+	case ActionEdit:
+		if command.Text == "" {
+			return fmt.Errorf("%w: edit requires text", ErrInvalidCommand)
+		}
+		q.text = command.Text
+	case ActionDelete:
+		delete(t.questionsByID, q.id)
+		remaining := make([]*question, 0, len(t.questions))
+		for _, other := range t.questions {
+			if other != q {
+				remaining = append(remaining, other)
 			}
-		}()
+		}
+		t.questions = remaining
+	default:
+		return fmt.Errorf(`%w: unknown action "%s"`, ErrInvalidCommand, command.Action)
+	}
+	log.Printf("Moderator %s %s #%d", command.Action, t.name, q.id)
+	t.notifyAllSubscribers()
+
+	return nil
+}
+
+func (t *TextCollector) subscribe(
+	ctx context.Context, policy notification.DeliveryPolicy, afterSeq uint64, moderator bool,
+) (<-chan notification.Sequenced[Messages], error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	n := t.notification
+	kind := "subscriber"
+	if moderator {
+		n = t.moderatorNotification
+		kind = "moderator subscriber"
+	}
+	subscription, err := n.SubscribeWithCurrent(ctx, policy, afterSeq, func() Messages {
+		return t.copyMessages(moderator)
+	})
+	if err != nil {
+		return nil, err
 	}
-	numSubs := t.notification.Subscribe(subscriber)
-	log.Printf("+1 %s subscriber (=%d)", t.name, numSubs)
+
+	return notification.LogSubscriber(n, t.name+" "+kind, subscription), nil
 }
 
-func (t *TextCollector) Unsubscribe(subscriber chan<- Messages) {
-	numSubs := t.notification.Unsubscribe(subscriber)
-	if numSubs == 0 {
-		t.chatMessageBroadcaster.Unsubscribe(t.messages)
-		t.messages = nil
+// Approved and answered questions, sent first unless afterSeq is the latest.
+func (t *TextCollector) Subscribe(
+	ctx context.Context, policy notification.DeliveryPolicy, afterSeq uint64,
+) (<-chan notification.Sequenced[Messages], error) {
+	return t.subscribe(ctx, policy, afterSeq, false)
+}
+
+// Questions in every state, sent first unless afterSeq is the latest.
+func (t *TextCollector) SubscribeModerator(
+	ctx context.Context, policy notification.DeliveryPolicy, afterSeq uint64,
+) (<-chan notification.Sequenced[Messages], error) {
+	return t.subscribe(ctx, policy, afterSeq, true)
+}
+
+// Messages that became pending questions, starting with any recent messages
+// numbered after afterSeq.
+func (t *TextCollector) SubscribeRejected(
+	ctx context.Context, policy notification.DeliveryPolicy, afterSeq uint64,
+) (<-chan notification.Sequenced[RejectedMessage], error) {
+	subscription, err := t.rejectedNotification.SubscribeAfter(ctx, policy, afterSeq)
+	if err != nil {
+		return nil, err
 	}
-	log.Printf("-1 %s subscriber (=%d)", t.name, numSubs)
+
+	return notification.LogSubscriber(t.rejectedNotification, t.name+" rejected message subscriber", subscription), nil
 }
 
+// Question IDs keep increasing, so that late upvotes and commands for
+// questions from before the reset don't apply to new questions.
 func (t *TextCollector) Reset() {
 	t.mutex.Lock()
 	defer t.mutex.Unlock()
-	t.chatText = make([]string, 0, t.initialCapacity)
+	t.questions = make([]*question, 0, t.initialCapacity)
+	t.questionsByID = make(map[int]*question, t.initialCapacity)
 	t.notifyAllSubscribers()
 }
 
+// Routes chat passed to NewMessage to consumers and questions, synchronously,
+// so that messages are counted in the order they were received relative to
+// resets and moderator commands, and replaying the event log assigns the same
+// question IDs.
 func NewMessageRouter(
-	name string, chatMessageBroadcaster, rejectedMessageBroadcaster *chat.Broadcaster, initialCapacity int,
+	name string, attribution Attribution, consumers []Consumer,
+	rejectedMessageBroadcaster *chat.Broadcaster, initialCapacity int,
 ) *TextCollector {
 	return &TextCollector{
 		name:                       name,
-		chatText:                   make([]string, 0, initialCapacity),
+		attribution:                attribution,
+		questions:                  make([]*question, 0, initialCapacity),
+		questionsByID:              make(map[int]*question, initialCapacity),
+		nextQuestionID:             1,
 		initialCapacity:            initialCapacity,
-		chatMessageBroadcaster:     chatMessageBroadcaster,
+		consumers:                  consumers,
 		rejectedMessageBroadcaster: rejectedMessageBroadcaster,
-		notification:               notification.NewNotification[Messages](),
+		notification:               notification.NewSequencedNotification[Messages](1),
+		moderatorNotification:      notification.NewSequencedNotification[Messages](1),
+		rejectedNotification:       notification.NewSequencedNotification[RejectedMessage](recentRejectedCapacity),
 	}
 }
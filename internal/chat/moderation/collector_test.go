@@ -0,0 +1,275 @@
+package moderation
+
+import (
+	"context"
+	"errors"
+	"presentation-service/internal/chat"
+	"presentation-service/internal/notification"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCollectsQuestionsWithoutSubscribers(t *testing.T) {
+	collector := NewMessageRouter("question", AttributionNames, nil, chat.NewBroadcaster("rejected"), 10)
+
+	collector.NewMessage(chat.Message{Sender: "Jane", Recipient: "Everyone", Text: "What is a goroutine?"})
+	collector.NewMessage(chat.Message{Sender: "", Recipient: "Everyone", Text: "Why Go?"})
+	if err := collector.Execute(Command{Action: ActionApprove, ID: 1}); err != nil {
+		t.Fatal(err)
+	}
+	questions, err := collector.SubscribeModerator(context.Background(), notification.CoalesceLatest(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var messages notification.Sequenced[Messages]
+	for len(messages.Value.Questions) < 2 {
+		select {
+		case messages = <-questions:
+		case <-time.After(time.Second):
+			t.Fatalf("received %+v, want 2 questions", messages.Value)
+		}
+	}
+
+	byID := map[int]Question{}
+	for _, q := range messages.Value.Questions {
+		byID[q.ID] = q
+	}
+	if q := byID[1]; q.Text != "What is a goroutine?" || q.Sender != "Jane" || q.State != StateApproved {
+		t.Errorf("question #1 is %+v, want Jane's approved question", q)
+	}
+	if q := byID[2]; q.Text != "Why Go?" || q.State != StateApproved {
+		t.Errorf("question #2 is %+v, want the moderator's question", q)
+	}
+}
+
+type fakeConsumer struct {
+	takes    string
+	received []chat.Message
+}
+
+func (c *fakeConsumer) NewMessage(message chat.Message) bool {
+	c.received = append(c.received, message)
+
+	return message.Text == c.takes
+}
+
+func TestRejectsOnlyMessagesNoConsumerTakes(t *testing.T) {
+	rejectedMessages := chat.NewBroadcaster("rejected")
+	rejected, err := rejectedMessages.Subscribe(context.Background(), notification.DropOldest(10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	goPoll, rustPoll := &fakeConsumer{takes: "Go"}, &fakeConsumer{takes: "Rust"}
+	collector := NewMessageRouter(
+		"question", AttributionNames, []Consumer{goPoll, rustPoll}, rejectedMessages, 10,
+	)
+
+	for _, text := range []string{"Go", "Rust", "What is a goroutine?"} {
+		collector.NewMessage(chat.Message{Sender: "Jane", Recipient: "Everyone", Text: text})
+	}
+
+	select {
+	case message := <-rejected:
+		if message.Text != "What is a goroutine?" {
+			t.Errorf("rejected %q, want the question", message.Text)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("nothing rejected, want the question")
+	}
+	select {
+	case message := <-rejected:
+		t.Errorf("rejected %q, want only the question rejected once", message.Text)
+	case <-time.After(50 * time.Millisecond):
+	}
+	if len(goPoll.received) != 3 || len(rustPoll.received) != 3 {
+		t.Errorf("consumers received %d and %d messages, want every message", len(goPoll.received), len(rustPoll.received))
+	}
+	if err = collector.Execute(Command{Action: ActionApprove, ID: 1}); err != nil {
+		t.Errorf("approving question #1 (%v), want the only question", err)
+	}
+	if err = collector.Execute(Command{Action: ActionApprove, ID: 2}); err == nil {
+		t.Error("approved question #2, want votes not to become questions")
+	}
+}
+
+func questionIDs(questions []Question) []int {
+	ids := make([]int, 0, len(questions))
+	for _, q := range questions {
+		ids = append(ids, q.ID)
+	}
+
+	return ids
+}
+
+func TestUpvotes(t *testing.T) {
+	collector := NewMessageRouter("question", AttributionNames, nil, chat.NewBroadcaster("rejected"), 10)
+	for _, text := range []string{"Why Go?", "Why generics?", "Why not Rust?"} {
+		collector.NewMessage(chat.Message{Text: text})
+	}
+	if err := collector.Execute(Command{Action: ActionReject, ID: 3}); err != nil {
+		t.Fatal(err)
+	}
+	collector.NewMessage(chat.Message{Sender: "Jane", Text: "What is a goroutine?"})
+	collector.NewMessage(chat.Message{Text: "Why channels?"})
+
+	for _, test := range []struct {
+		id      int
+		sender  string
+		wantErr error
+	}{
+		{id: 2, sender: "Jane"},
+		{id: 2, sender: "Bob"},
+		{id: 2, sender: "Jane", wantErr: ErrAlreadyVoted},
+		{id: 3, sender: "Ann", wantErr: ErrQuestionNotFound}, // Hidden
+		{id: 4, sender: "Ann", wantErr: ErrQuestionNotFound}, // Pending
+		{id: 9, sender: "Ann", wantErr: ErrQuestionNotFound},
+		{id: 5, sender: "Cam"},
+		{id: 1, sender: "Cam"},
+	} {
+		if err := collector.Upvote(test.id, test.sender); !errors.Is(err, test.wantErr) {
+			t.Errorf("%s upvoting #%d returned %v, want %v", test.sender, test.id, err, test.wantErr)
+		}
+	}
+
+	// Most votes first, then oldest first
+	messages := collector.copyMessages(false)
+	if ids := questionIDs(messages.Questions); !reflect.DeepEqual(ids, []int{2, 1, 5}) {
+		t.Errorf("ranked %v, want [2 1 5]", ids)
+	}
+	votes := make([]int, 0, len(messages.Questions))
+	for _, q := range messages.Questions {
+		votes = append(votes, q.Votes)
+	}
+	if !reflect.DeepEqual(votes, []int{2, 1, 1}) {
+		t.Errorf("votes %v, want [2 1 1]", votes)
+	}
+	if want := []string{"Why generics?", "Why Go?", "Why channels?"}; !reflect.DeepEqual(messages.ChatText, want) {
+		t.Errorf("chat text %v, want %v", messages.ChatText, want)
+	}
+}
+
+func TestUpvoteMessages(t *testing.T) {
+	collector := NewMessageRouter("question", AttributionNames, nil, chat.NewBroadcaster("rejected"), 10)
+	collector.NewMessage(chat.Message{Text: "Why Go?"})
+
+	for _, test := range []struct {
+		message       chat.Message
+		wantVotes     int
+		wantQuestions int
+	}{
+		{message: chat.Message{Sender: "Jane", Text: "+1 #1"}, wantVotes: 1, wantQuestions: 1},
+		{message: chat.Message{Sender: "Bob", Text: "  +1   #1 "}, wantVotes: 2, wantQuestions: 1},
+		{message: chat.Message{Sender: "Bob", Text: "+1 #1"}, wantVotes: 2, wantQuestions: 1},
+		{message: chat.Message{Sender: "Ann", Text: "+1 #1 me too"}, wantVotes: 2, wantQuestions: 2},
+		{message: chat.Message{Sender: "Ann", Text: "+1"}, wantVotes: 2, wantQuestions: 3},
+		// The moderator's messages are questions
+		{message: chat.Message{Text: "+1 #1"}, wantVotes: 2, wantQuestions: 4},
+	} {
+		collector.NewMessage(test.message)
+		messages := collector.copyMessages(true)
+		if len(messages.Questions) != test.wantQuestions || messages.Questions[0].Votes != test.wantVotes {
+			t.Errorf(
+				"after %q by %q, %d questions and %d votes for #1, want %d and %d", test.message.Text,
+				test.message.Sender, len(messages.Questions), messages.Questions[0].Votes,
+				test.wantQuestions, test.wantVotes,
+			)
+		}
+	}
+}
+
+func TestQuestionIDsIncreaseAcrossResets(t *testing.T) {
+	collector := NewMessageRouter("question", AttributionNames, nil, chat.NewBroadcaster("rejected"), 10)
+	collector.NewMessage(chat.Message{Text: "Why Go?"})
+	collector.NewMessage(chat.Message{Text: "Why generics?"})
+
+	collector.Reset()
+	collector.NewMessage(chat.Message{Text: "Why channels?"})
+
+	if ids := questionIDs(collector.copyMessages(true).Questions); !reflect.DeepEqual(ids, []int{3}) {
+		t.Errorf("question IDs %v after reset, want [3]", ids)
+	}
+	// Late votes and commands for questions from before the reset
+	if err := collector.Upvote(1, "Jane"); !errors.Is(err, ErrQuestionNotFound) {
+		t.Errorf("upvoting #1 returned %v, want ErrQuestionNotFound", err)
+	}
+	if err := collector.Execute(Command{Action: ActionAnswer, ID: 2}); !errors.Is(err, ErrQuestionNotFound) {
+		t.Errorf("answering #2 returned %v, want ErrQuestionNotFound", err)
+	}
+	if votes := collector.copyMessages(true).Questions[0].Votes; votes != 0 {
+		t.Errorf("%d votes for the new question, want 0", votes)
+	}
+}
+
+func TestExecute(t *testing.T) {
+	for _, test := range []struct {
+		name      string
+		from      State
+		command   Command
+		wantErr   error
+		wantState State // Empty if deleted
+		wantText  string
+	}{
+		{name: "approve pending", from: StatePending, command: Command{Action: ActionApprove, ID: 1},
+			wantState: StateApproved, wantText: "Why Go?"},
+		{name: "approve hidden", from: StateHidden, command: Command{Action: ActionApprove, ID: 1},
+			wantState: StateApproved, wantText: "Why Go?"},
+		{name: "approve answered", from: StateAnswered, command: Command{Action: ActionApprove, ID: 1},
+			wantState: StateApproved, wantText: "Why Go?"},
+		{name: "reject pending", from: StatePending, command: Command{Action: ActionReject, ID: 1},
+			wantState: StateHidden, wantText: "Why Go?"},
+		{name: "reject approved", from: StateApproved, command: Command{Action: ActionReject, ID: 1},
+			wantState: StateHidden, wantText: "Why Go?"},
+		{name: "reject answered", from: StateAnswered, command: Command{Action: ActionReject, ID: 1},
+			wantState: StateHidden, wantText: "Why Go?"},
+		{name: "answer approved", from: StateApproved, command: Command{Action: ActionAnswer, ID: 1},
+			wantState: StateAnswered, wantText: "Why Go?"},
+		{name: "answer answered", from: StateAnswered, command: Command{Action: ActionAnswer, ID: 1},
+			wantState: StateAnswered, wantText: "Why Go?"},
+		{name: "answer pending", from: StatePending, command: Command{Action: ActionAnswer, ID: 1},
+			wantErr: ErrInvalidCommand, wantState: StatePending, wantText: "Why Go?"},
+		{name: "answer hidden", from: StateHidden, command: Command{Action: ActionAnswer, ID: 1},
+			wantErr: ErrInvalidCommand, wantState: StateHidden, wantText: "Why Go?"},
+		{name: "edit pending", from: StatePending, command: Command{Action: ActionEdit, ID: 1, Text: "Why Golang?"},
+			wantState: StatePending, wantText: "Why Golang?"},
+		{name: "edit approved", from: StateApproved, command: Command{Action: ActionEdit, ID: 1, Text: "Why Golang?"},
+			wantState: StateApproved, wantText: "Why Golang?"},
+		{name: "edit without text", from: StateApproved, command: Command{Action: ActionEdit, ID: 1},
+			wantErr: ErrInvalidCommand, wantState: StateApproved, wantText: "Why Go?"},
+		{name: "delete pending", from: StatePending, command: Command{Action: ActionDelete, ID: 1}},
+		{name: "delete approved", from: StateApproved, command: Command{Action: ActionDelete, ID: 1}},
+		{name: "delete hidden", from: StateHidden, command: Command{Action: ActionDelete, ID: 1}},
+		{name: "unknown action", from: StateApproved, command: Command{Action: "pin", ID: 1},
+			wantErr: ErrInvalidCommand, wantState: StateApproved, wantText: "Why Go?"},
+		{name: "unknown ID", from: StatePending, command: Command{Action: ActionApprove, ID: 2},
+			wantErr: ErrQuestionNotFound, wantState: StatePending, wantText: "Why Go?"},
+		{name: "no ID", from: StatePending, command: Command{Action: ActionDelete},
+			wantErr: ErrQuestionNotFound, wantState: StatePending, wantText: "Why Go?"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			collector := NewMessageRouter("question", AttributionNames, nil, chat.NewBroadcaster("rejected"), 10)
+			collector.NewMessage(chat.Message{Text: "Why Go?"})
+			collector.questionsByID[1].state = test.from
+
+			if err := collector.Execute(test.command); !errors.Is(err, test.wantErr) {
+				t.Errorf("returned %v, want %v", err, test.wantErr)
+			}
+			questions := collector.copyMessages(true).Questions
+			if test.wantState == "" {
+				if len(questions) != 0 {
+					t.Errorf("questions are %+v, want none", questions)
+				}
+				if _, ok := collector.questionsByID[1]; ok {
+					t.Error("deleted question #1 can still be found")
+				}
+				return
+			}
+			if len(questions) != 1 {
+				t.Fatalf("questions are %+v, want question #1", questions)
+			}
+			if q := questions[0]; q.State != test.wantState || q.Text != test.wantText {
+				t.Errorf("question is %s %q, want %s %q", q.State, q.Text, test.wantState, test.wantText)
+			}
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package moderation
+
+import (
+	"context"
+	"log"
+	"presentation-service/internal/notification"
+	"presentation-service/internal/token"
+)
+
+// Matches kept for subscribers resuming with Subscribe.
+const recentFuzzyMatchesCapacity = 64
+
+// Reports which token misspelled words were resolved to, for review.
+type FuzzyMatchBroadcaster struct {
+	notification *notification.SequencedNotification[token.FuzzyMatch]
+}
+
+func (b *FuzzyMatchBroadcaster) NewFuzzyMatch(match token.FuzzyMatch) {
+	log.Printf(`Fuzzy matched "%s" to "%s" (distance %d)`, match.Word, match.Token, match.Distance)
+	b.notification.NotifyAll(match)
+}
+
+// Recent matches numbered after afterSeq are sent first, if afterSeq is not 0.
+func (b *FuzzyMatchBroadcaster) Subscribe(
+	ctx context.Context, policy notification.DeliveryPolicy, afterSeq uint64,
+) (<-chan notification.Sequenced[token.FuzzyMatch], error) {
+	subscription, err := b.notification.SubscribeAfter(ctx, policy, afterSeq)
+	if err != nil {
+		return nil, err
+	}
+
+	return notification.LogSubscriber(b.notification, "fuzzy match subscriber", subscription), nil
+}
+
+func NewFuzzyMatchBroadcaster() *FuzzyMatchBroadcaster {
+	return &FuzzyMatchBroadcaster{
+		notification: notification.NewSequencedNotification[token.FuzzyMatch](recentFuzzyMatchesCapacity),
+	}
+}
@@ -1,5 +1,131 @@
 package moderation
 
+import (
+	"presentation-service/internal/chat"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+type State string
+
+const (
+	StatePending  State = "pending"
+	StateApproved State = "approved"
+	StateAnswered State = "answered"
+	StateHidden   State = "hidden"
+)
+
+// Shown on the presentation
+func (s State) visible() bool {
+	return s == StateApproved || s == StateAnswered
+}
+
+type Action string
+
+const (
+	ActionApprove Action = "approve"
+	ActionReject  Action = "reject"
+	ActionAnswer  Action = "answer"
+	ActionEdit    Action = "edit"
+	ActionDelete  Action = "delete"
+)
+
+type Command struct {
+	Action Action `json:"action"`
+	ID     int    `json:"id"`
+	Text   string `json:"text,omitempty"` // For ActionEdit
+}
+
+// A chat message that no poll or quiz took, pending as question ID.
+type RejectedMessage struct {
+	ID int `json:"id"`
+	chat.Message
+}
+
+// How question senders are shown on the presentation.
+type Attribution string
+
+const (
+	AttributionNames     Attribution = "names"
+	AttributionInitials  Attribution = "initials"
+	AttributionAnonymous Attribution = "anonymous"
+)
+
+const anonymousLabel = "Anonymous"
+
+func (a Attribution) Valid() bool {
+	return a == AttributionNames || a == AttributionInitials || a == AttributionAnonymous
+}
+
+// Questions from the moderator have no sender, and are never attributed.
+func (a Attribution) label(sender string) string {
+	if sender == "" {
+		return ""
+	}
+	switch a {
+	case AttributionNames:
+		return sender
+	case AttributionInitials:
+		var initials strings.Builder
+		for _, name := range strings.FieldsFunc(sender, func(r rune) bool {
+			return unicode.IsSpace(r) || r == '-'
+		}) {
+			initial, _ := utf8.DecodeRuneInString(name)
+			initials.WriteRune(unicode.ToUpper(initial))
+			initials.WriteRune('.')
+		}
+		if initials.Len() == 0 {
+			return anonymousLabel
+		}
+		return initials.String()
+	default:
+		return anonymousLabel
+	}
+}
+
+type Question struct {
+	ID     int       `json:"id"`
+	Text   string    `json:"text"`
+	Sender string    `json:"sender,omitempty"`
+	Time   time.Time `json:"time"`
+	Votes  int       `json:"votes"`
+	State  State     `json:"state"`
+}
+
 type Messages struct {
-	ChatText []string `json:"chatText"`
+	ChatText  []string   `json:"chatText"` // Question text, in ranked order
+	Questions []Question `json:"questions"`
+}
+
+// Non-threadsafe - only share copies!
+type question struct {
+	id     int
+	text   string
+	sender string
+	time   time.Time
+	state  State
+	voters map[string]struct{}
+}
+
+// Mutates state
+func (q *question) vote(sender string) bool {
+	if _, voted := q.voters[sender]; voted {
+		return false
+	}
+	q.voters[sender] = struct{}{}
+
+	return true
+}
+
+// Most votes first, oldest first amongst equal votes.
+func rankQuestions(questions []Question) {
+	sort.SliceStable(questions, func(i, j int) bool {
+		if questions[i].Votes != questions[j].Votes {
+			return questions[i].Votes > questions[j].Votes
+		}
+		return questions[i].ID < questions[j].ID
+	})
 }
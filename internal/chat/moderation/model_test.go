@@ -0,0 +1,31 @@
+package moderation
+
+import "testing"
+
+func TestAttributionLabel(t *testing.T) {
+	for _, test := range []struct {
+		attribution Attribution
+		sender      string
+		want        string
+	}{
+		{attribution: AttributionNames, sender: "Jane Doe", want: "Jane Doe"},
+		{attribution: AttributionNames, sender: "Jane", want: "Jane"},
+		{attribution: AttributionInitials, sender: "Jane Doe", want: "J.D."},
+		{attribution: AttributionInitials, sender: "Jane", want: "J."},
+		{attribution: AttributionInitials, sender: "jane  van doe ", want: "J.V.D."},
+		{attribution: AttributionInitials, sender: "Jean-Luc Picard", want: "J.L.P."},
+		{attribution: AttributionInitials, sender: "Émile Zola", want: "É.Z."},
+		{attribution: AttributionInitials, sender: "ängel øyvind", want: "Ä.Ø."},
+		{attribution: AttributionInitials, sender: "李 小龍", want: "李.小."},
+		{attribution: AttributionInitials, sender: " - ", want: anonymousLabel},
+		{attribution: AttributionAnonymous, sender: "Jane Doe", want: anonymousLabel},
+		// The moderator
+		{attribution: AttributionNames, sender: "", want: ""},
+		{attribution: AttributionInitials, sender: "", want: ""},
+		{attribution: AttributionAnonymous, sender: "", want: ""},
+	} {
+		if label := test.attribution.label(test.sender); label != test.want {
+			t.Errorf("%s label for %q is %q, want %q", test.attribution, test.sender, label, test.want)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package quiz
+
+import (
+	"sort"
+	"time"
+)
+
+type Question struct {
+	Text string
+	// Correct token, as extracted from chat messages
+	Answer    string
+	TimeLimit time.Duration
+	// For a correct answer, plus up to half as much again for answering quickly
+	Points int
+}
+
+// Speed bonus decreases linearly, from half of Points when the question opens,
+// to nothing at the time limit.
+func (q Question) score(elapsed time.Duration) int {
+	remaining := q.TimeLimit - elapsed
+	if remaining < 0 {
+		remaining = 0
+	}
+
+	return q.Points + int(float64(q.Points)/2*remaining.Seconds()/q.TimeLimit.Seconds())
+}
+
+type CurrentQuestion struct {
+	Number   int       `json:"number"` // From 1
+	Total    int       `json:"total"`
+	Text     string    `json:"text"`
+	Deadline time.Time `json:"deadline"`
+	Closed   bool      `json:"closed"`
+	Answers  int       `json:"answers"`
+	Answer   string    `json:"answer,omitempty"`  // Once closed
+	Correct  int       `json:"correct,omitempty"` // Once closed
+}
+
+type Entry struct {
+	Rank    int    `json:"rank"` // Equal scores share a rank
+	Sender  string `json:"sender"`
+	Score   int    `json:"score"`
+	Correct int    `json:"correct"`
+}
+
+type Leaderboard struct {
+	Question *CurrentQuestion `json:"question,omitempty"` // Nil before the first question
+	Finished bool             `json:"finished"`
+	Entries  []Entry          `json:"entries"` // Highest score first
+}
+
+type score struct {
+	points  int
+	correct int
+}
+
+// Highest score first, then most correct answers, then by sender.
+func rankEntries(entries []Entry) {
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Score != entries[j].Score {
+			return entries[i].Score > entries[j].Score
+		}
+		if entries[i].Correct != entries[j].Correct {
+			return entries[i].Correct > entries[j].Correct
+		}
+		return entries[i].Sender < entries[j].Sender
+	})
+	for i := range entries {
+		if i > 0 && entries[i-1].Score == entries[i].Score {
+			entries[i].Rank = entries[i-1].Rank
+		} else {
+			entries[i].Rank = i + 1
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package quiz
+
+import (
+	"context"
+	"errors"
+	"log"
+	"presentation-service/internal/chat"
+	"presentation-service/internal/notification"
+	"strings"
+	"sync"
+	"time"
+)
+
+const batchPeriodMillis = 100
+
+var ErrFinished = errors.New("quiz finished")
+
+type Quiz struct {
+	name                string
+	questions           []Question
+	leaderboardSize     int
+	extractTokens       func(string) []string
+	current             int // -1 before the first question, len(questions) once finished
+	openedAt            time.Time
+	answersBySender     map[string]string // For the current question
+	scoresBySender      map[string]*score
+	closeTimer          *time.Timer
+	mutex               sync.RWMutex
+	notification        *notification.SequencedNotification[Leaderboard]
+	awaitingNotify      bool
+	awaitingNotifyMutex sync.Mutex
+}
+
+// Caller must hold mutex
+func (q *Quiz) deadline() time.Time {
+	return q.openedAt.Add(q.questions[q.current].TimeLimit)
+}
+
+func (q *Quiz) copyLeaderboard() Leaderboard {
+	q.mutex.RLock()
+	defer q.mutex.RUnlock()
+	leaderboard := Leaderboard{
+		Finished: q.current >= len(q.questions),
+		Entries:  make([]Entry, 0, len(q.scoresBySender)),
+	}
+	if q.current >= 0 && q.current < len(q.questions) {
+		question := q.questions[q.current]
+		current := &CurrentQuestion{
+			Number:   q.current + 1,
+			Total:    len(q.questions),
+			Text:     question.Text,
+			Deadline: q.deadline(),
+			Closed:   !time.Now().Before(q.deadline()),
+			Answers:  len(q.answersBySender),
+		}
+		if current.Closed {
+			current.Answer = question.Answer
+			for _, answer := range q.answersBySender {
+				if strings.EqualFold(answer, question.Answer) {
+					current.Correct++
+				}
+			}
+		}
+		leaderboard.Question = current
+	}
+	for sender, s := range q.scoresBySender {
+		leaderboard.Entries = append(leaderboard.Entries, Entry{
+			Sender: sender, Score: s.points, Correct: s.correct,
+		})
+	}
+	rankEntries(leaderboard.Entries)
+	if q.leaderboardSize > 0 && len(leaderboard.Entries) > q.leaderboardSize {
+		leaderboard.Entries = leaderboard.Entries[:q.leaderboardSize]
+	}
+
+	return leaderboard
+}
+
+func (q *Quiz) notifyAllSubscribers() {
+	q.notification.NotifyAll(q.copyLeaderboard())
+}
+
+func (q *Quiz) scheduleNotification() {
+	q.awaitingNotifyMutex.Lock()
+	defer q.awaitingNotifyMutex.Unlock()
+	if !q.awaitingNotify {
+		time.AfterFunc(batchPeriodMillis*time.Millisecond, func() {
+			q.notifyAllSubscribers()
+			q.awaitingNotifyMutex.Lock()
+			defer q.awaitingNotifyMutex.Unlock()
+			q.awaitingNotify = false
+		})
+		q.awaitingNotify = true
+	}
+}
+
+// Reports whether the message answered the current question. Only each
+// sender's first answer counts, if it is sent before the time limit.
+// Messages without a sender are ignored.
+func (q *Quiz) NewMessage(message chat.Message) bool {
+	if message.Sender == "" {
+		return false
+	}
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	if q.current < 0 || q.current >= len(q.questions) {
+		return false
+	}
+	tokens := q.extractTokens(message.Text)
+	if len(tokens) == 0 {
+		return false
+	}
+	// Late and repeated answers are still answers, just not scored
+	if _, answered := q.answersBySender[message.Sender]; answered {
+		return true
+	}
+	answeredAt := message.Time
+	if answeredAt.IsZero() {
+		answeredAt = time.Now()
+	}
+	if answeredAt.Before(q.openedAt) || answeredAt.After(q.deadline()) {
+		return true
+	}
+
+	question := q.questions[q.current]
+	q.answersBySender[message.Sender] = tokens[0]
+	s, ok := q.scoresBySender[message.Sender]
+	if !ok {
+		s = &score{}
+		q.scoresBySender[message.Sender] = s
+	}
+	if strings.EqualFold(tokens[0], question.Answer) {
+		s.points += question.score(answeredAt.Sub(q.openedAt))
+		s.correct++
+	}
+	log.Printf(`%s answered "%s" to %s question %d`, message.Sender, tokens[0], q.name, q.current+1)
+	q.scheduleNotification()
+
+	return true
+}
+
+// Opens the next question at openedAt, or finishes the quiz after the last
+// question. Returns ErrFinished if the quiz has already finished.
+func (q *Quiz) Next(openedAt time.Time) error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	if q.current >= len(q.questions) {
+		return ErrFinished
+	}
+	if q.closeTimer != nil {
+		q.closeTimer.Stop()
+		q.closeTimer = nil
+	}
+	q.current++
+	q.openedAt = openedAt
+	q.answersBySender = map[string]string{}
+	if q.current < len(q.questions) {
+		log.Printf("Opened %s question %d", q.name, q.current+1)
+		// Reveal the answer once the time limit passes
+		if untilDeadline := time.Until(q.deadline()); untilDeadline > 0 {
+			q.closeTimer = time.AfterFunc(untilDeadline, q.scheduleNotification)
+		}
+	} else {
+		log.Printf("Finished %s", q.name)
+	}
+	q.scheduleNotification()
+
+	return nil
+}
+
+// The current leaderboard is sent first, unless afterSeq is the latest.
+func (q *Quiz) Subscribe(
+	ctx context.Context, policy notification.DeliveryPolicy, afterSeq uint64,
+) (<-chan notification.Sequenced[Leaderboard], error) {
+	subscription, err := q.notification.SubscribeWithCurrent(ctx, policy, afterSeq, q.copyLeaderboard)
+	if err != nil {
+		return nil, err
+	}
+
+	return notification.LogSubscriber(q.notification, q.name+" notification", subscription), nil
+}
+
+func (q *Quiz) Reset() {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	if q.closeTimer != nil {
+		q.closeTimer.Stop()
+		q.closeTimer = nil
+	}
+	q.current = -1
+	q.answersBySender = map[string]string{}
+	q.scoresBySender = map[string]*score{}
+
+	q.scheduleNotification()
+}
+
+// Answers are extracted with extractTokens, using the first token of each
+// message. If leaderboardSize is positive, only the top entries are reported.
+func NewQuiz(
+	name string, questions []Question, leaderboardSize int, extractTokens func(string) []string,
+) *Quiz {
+	return &Quiz{
+		name:            name,
+		questions:       questions,
+		leaderboardSize: leaderboardSize,
+		extractTokens:   extractTokens,
+		current:         -1,
+		answersBySender: map[string]string{},
+		scoresBySender:  map[string]*score{},
+		notification:    notification.NewSequencedNotification[Leaderboard](1),
+	}
+}
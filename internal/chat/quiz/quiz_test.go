@@ -0,0 +1,247 @@
+package quiz
+
+import (
+	"errors"
+	"presentation-service/internal/chat"
+	"presentation-service/internal/token"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var testQuestions = []Question{
+	{Text: "Which is a language?", Answer: "A", TimeLimit: 10 * time.Second, Points: 10},
+	{Text: "Which is an editor?", Answer: "C", TimeLimit: 10 * time.Second, Points: 10},
+}
+
+func newTestQuiz(leaderboardSize int) *Quiz {
+	return NewQuiz("test-quiz", testQuestions, leaderboardSize, token.NewChoiceExtractor(token.DefaultChoices))
+}
+
+type answer struct {
+	sender string
+	text   string
+	after  time.Duration // Since the question opened
+}
+
+func TestAnswers(t *testing.T) {
+	for _, test := range []struct {
+		name        string
+		answers     []answer
+		wantAnswers []bool
+		want        []Entry
+	}{
+		{
+			name:        "correct immediately scores the full speed bonus",
+			answers:     []answer{{sender: "Jane", text: "a"}},
+			wantAnswers: []bool{true},
+			want:        []Entry{{Rank: 1, Sender: "Jane", Score: 15, Correct: 1}},
+		},
+		{
+			name:        "correct halfway scores half the speed bonus",
+			answers:     []answer{{sender: "Jane", text: "A", after: 5 * time.Second}},
+			wantAnswers: []bool{true},
+			want:        []Entry{{Rank: 1, Sender: "Jane", Score: 12, Correct: 1}},
+		},
+		{
+			name:        "correct at the time limit scores no speed bonus",
+			answers:     []answer{{sender: "Jane", text: "(a)", after: 10 * time.Second}},
+			wantAnswers: []bool{true},
+			want:        []Entry{{Rank: 1, Sender: "Jane", Score: 10, Correct: 1}},
+		},
+		{
+			name:        "incorrect",
+			answers:     []answer{{sender: "Jane", text: "b"}},
+			wantAnswers: []bool{true},
+			want:        []Entry{{Rank: 1, Sender: "Jane"}},
+		},
+		{
+			name:        "late",
+			answers:     []answer{{sender: "Jane", text: "a", after: 11 * time.Second}},
+			wantAnswers: []bool{true},
+			want:        []Entry{},
+		},
+		{
+			name:        "before the question opened",
+			answers:     []answer{{sender: "Jane", text: "a", after: -time.Second}},
+			wantAnswers: []bool{true},
+			want:        []Entry{},
+		},
+		{
+			name: "only the first answer by a sender counts",
+			answers: []answer{
+				{sender: "Jane", text: "b"},
+				{sender: "Jane", text: "a", after: time.Second},
+				{sender: "Bob", text: "a", after: 2 * time.Second},
+				{sender: "Bob", text: "a", after: 3 * time.Second},
+			},
+			wantAnswers: []bool{true, true, true, true},
+			want: []Entry{
+				{Rank: 1, Sender: "Bob", Score: 14, Correct: 1},
+				{Rank: 2, Sender: "Jane"},
+			},
+		},
+		{
+			name: "not answers",
+			answers: []answer{
+				{sender: "Jane", text: "I think a"},
+				{sender: "", text: "a"},
+			},
+			wantAnswers: []bool{false, false},
+			want:        []Entry{},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			q := newTestQuiz(0)
+			openedAt := time.Now().Add(-time.Minute)
+			if err := q.Next(openedAt); err != nil {
+				t.Fatal(err)
+			}
+			for i, a := range test.answers {
+				answered := q.NewMessage(chat.Message{Sender: a.sender, Text: a.text, Time: openedAt.Add(a.after)})
+				if answered != test.wantAnswers[i] {
+					t.Errorf("%q by %q answered: %t, want %t", a.text, a.sender, answered, test.wantAnswers[i])
+				}
+			}
+
+			if entries := q.copyLeaderboard().Entries; !reflect.DeepEqual(entries, test.want) {
+				t.Errorf("leaderboard %+v, want %+v", entries, test.want)
+			}
+		})
+	}
+}
+
+func TestCurrentQuestion(t *testing.T) {
+	q := newTestQuiz(0)
+	if leaderboard := q.copyLeaderboard(); leaderboard.Question != nil || leaderboard.Finished {
+		t.Errorf("leaderboard %+v before the first question, want no question", leaderboard)
+	}
+	q.NewMessage(chat.Message{Sender: "Jane", Text: "a"})
+	if entries := q.copyLeaderboard().Entries; len(entries) != 0 {
+		t.Errorf("leaderboard %+v, want answers before the first question ignored", entries)
+	}
+
+	if err := q.Next(time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	q.NewMessage(chat.Message{Sender: "Jane", Text: "a"})
+	q.NewMessage(chat.Message{Sender: "Bob", Text: "b"})
+	current := q.copyLeaderboard().Question
+	if current == nil || current.Number != 1 || current.Total != 2 || current.Closed || current.Answers != 2 ||
+		current.Answer != "" || current.Correct != 0 {
+		t.Errorf("question %+v, want question 1 of 2 open, with 2 answers and the answer hidden", current)
+	}
+
+	// Replayed questions open at the logged time, so may already be closed
+	openedAt := time.Now().Add(-time.Minute)
+	if err := q.Next(openedAt); err != nil {
+		t.Fatal(err)
+	}
+	q.NewMessage(chat.Message{Sender: "Jane", Text: "c", Time: openedAt.Add(time.Second)})
+	q.NewMessage(chat.Message{Sender: "Bob", Text: "d", Time: openedAt.Add(time.Second)})
+	current = q.copyLeaderboard().Question
+	if current == nil || current.Number != 2 || !current.Closed || current.Answers != 2 ||
+		current.Answer != "C" || current.Correct != 1 || !current.Deadline.Equal(openedAt.Add(10*time.Second)) {
+		t.Errorf("question %+v, want question 2 closed, with 1 of 2 answers correct", current)
+	}
+
+	if err := q.Next(time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if leaderboard := q.copyLeaderboard(); leaderboard.Question != nil || !leaderboard.Finished {
+		t.Errorf("leaderboard %+v, want finished", leaderboard)
+	}
+	if err := q.Next(time.Now()); !errors.Is(err, ErrFinished) {
+		t.Errorf("next returned %v, want ErrFinished", err)
+	}
+
+	q.Reset()
+	leaderboard := q.copyLeaderboard()
+	if leaderboard.Question != nil || leaderboard.Finished || len(leaderboard.Entries) != 0 {
+		t.Errorf("leaderboard %+v after reset, want no question or entries", leaderboard)
+	}
+}
+
+func TestLeaderboardOrder(t *testing.T) {
+	q := newTestQuiz(3)
+	openedAt := time.Now().Add(-time.Minute)
+	for i, answers := range [][]answer{
+		{
+			{sender: "Cam", text: "a"},
+			{sender: "Bob", text: "a", after: 10 * time.Second},
+			{sender: "Ann", text: "b"},
+			{sender: "Dee", text: "a", after: 10 * time.Second},
+			{sender: "Eve", text: "a", after: 5 * time.Second},
+		},
+		{
+			{sender: "Ann", text: "c"},
+			{sender: "Bob", text: "d"},
+			{sender: "Dee", text: "d"},
+			{sender: "Eve", text: "d"},
+		},
+	} {
+		questionOpenedAt := openedAt.Add(time.Duration(i) * 20 * time.Second)
+		if err := q.Next(questionOpenedAt); err != nil {
+			t.Fatal(err)
+		}
+		for _, a := range answers {
+			q.NewMessage(chat.Message{Sender: a.sender, Text: a.text, Time: questionOpenedAt.Add(a.after)})
+		}
+	}
+
+	// Equal scores share a rank, ordered by sender. Only the top 3 are reported
+	want := []Entry{
+		{Rank: 1, Sender: "Ann", Score: 15, Correct: 1},
+		{Rank: 1, Sender: "Cam", Score: 15, Correct: 1},
+		{Rank: 3, Sender: "Eve", Score: 12, Correct: 1},
+	}
+	if entries := q.copyLeaderboard().Entries; !reflect.DeepEqual(entries, want) {
+		t.Errorf("leaderboard %+v, want %+v", entries, want)
+	}
+}
+
+func TestRankEntries(t *testing.T) {
+	entries := []Entry{
+		{Sender: "Bob", Score: 10, Correct: 1},
+		{Sender: "Ann", Score: 0},
+		{Sender: "Cam", Score: 10, Correct: 2},
+		{Sender: "Dee", Score: 12, Correct: 1},
+		{Sender: "Amy", Score: 10, Correct: 1},
+	}
+	rankEntries(entries)
+
+	// More correct answers go first among equal scores, but share the rank
+	want := []Entry{
+		{Rank: 1, Sender: "Dee", Score: 12, Correct: 1},
+		{Rank: 2, Sender: "Cam", Score: 10, Correct: 2},
+		{Rank: 2, Sender: "Amy", Score: 10, Correct: 1},
+		{Rank: 2, Sender: "Bob", Score: 10, Correct: 1},
+		{Rank: 5, Sender: "Ann", Score: 0},
+	}
+	if !reflect.DeepEqual(entries, want) {
+		t.Errorf("ranked %+v, want %+v", entries, want)
+	}
+}
+
+func TestScore(t *testing.T) {
+	for _, test := range []struct {
+		points  int
+		elapsed time.Duration
+		want    int
+	}{
+		{points: 10, elapsed: 0, want: 15},
+		{points: 10, elapsed: 5 * time.Second, want: 12},
+		// Odd points have a fractional speed bonus, truncated only once scored
+		{points: 5, elapsed: 0, want: 7},
+		{points: 5, elapsed: 2 * time.Second, want: 7},
+		{points: 5, elapsed: 6 * time.Second, want: 6},
+		{points: 1, elapsed: 0, want: 1},
+		{points: 5, elapsed: 10 * time.Second, want: 5},
+		{points: 5, elapsed: 11 * time.Second, want: 5},
+	} {
+		q := Question{Text: "Which is a language?", Answer: "A", TimeLimit: 10 * time.Second, Points: test.points}
+		if score := q.score(test.elapsed); score != test.want {
+			t.Errorf("%d points after %s scored %d, want %d", test.points, test.elapsed, score, test.want)
+		}
+	}
+}
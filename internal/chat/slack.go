@@ -0,0 +1,108 @@
+package chat
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Older requests may be replayed.
+const slackMaxRequestAge = 5 * time.Minute
+
+type slackPayload struct {
+	Type      string `json:"type"`
+	Challenge string `json:"challenge"`
+	Event     struct {
+		Type        string `json:"type"`
+		Subtype     string `json:"subtype"`
+		BotID       string `json:"bot_id"`
+		User        string `json:"user"`
+		UserProfile *struct {
+			DisplayName string `json:"display_name"`
+			RealName    string `json:"real_name"`
+		} `json:"user_profile"`
+		Text        string `json:"text"`
+		ChannelType string `json:"channel_type"`
+	} `json:"event"`
+}
+
+// Slack Events API requests, subscribed to message events. Requests are
+// verified if there is a SigningSecret.
+type SlackSource struct {
+	SigningSecret string
+}
+
+// See https://api.slack.com/authentication/verifying-requests-from-slack
+func (s SlackSource) verify(r *http.Request, body []byte, now time.Time) error {
+	timestamp := r.Header.Get("X-Slack-Request-Timestamp")
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("%w: missing Slack request timestamp", ErrUnverifiedPayload)
+	}
+	if age := now.Sub(time.Unix(seconds, 0)); age > slackMaxRequestAge || age < -slackMaxRequestAge {
+		return fmt.Errorf("%w: Slack request timestamp is %v old", ErrUnverifiedPayload, age)
+	}
+
+	mac := hmac.New(sha256.New, []byte(s.SigningSecret))
+	mac.Write([]byte("v0:" + timestamp + ":"))
+	mac.Write(body)
+	signature, err := hex.DecodeString(strings.TrimPrefix(r.Header.Get("X-Slack-Signature"), "v0="))
+	if err != nil || !hmac.Equal(signature, mac.Sum(nil)) {
+		return fmt.Errorf("%w: invalid Slack signature", ErrUnverifiedPayload)
+	}
+
+	return nil
+}
+
+func (s SlackSource) Verifies() bool {
+	return s.SigningSecret != ""
+}
+
+func (s SlackSource) Messages(r *http.Request, now time.Time) ([]Message, error) {
+	body, err := readPayload(r)
+	if err != nil {
+		return nil, err
+	}
+	if s.SigningSecret != "" {
+		if err = s.verify(r, body, now); err != nil {
+			return nil, err
+		}
+	}
+	var payload slackPayload
+	if err = decodePayload(body, &payload); err != nil {
+		return nil, err
+	}
+	if payload.Type == "url_verification" {
+		return nil, &Challenge{Response: payload.Challenge}
+	}
+	event := payload.Event
+	// Subtypes are edits, deletions, joins and the like
+	if payload.Type != "event_callback" || event.Type != "message" ||
+		event.Subtype != "" || event.BotID != "" || strings.TrimSpace(event.Text) == "" {
+		return nil, nil
+	}
+
+	sender := event.User
+	if profile := event.UserProfile; profile != nil {
+		if profile.DisplayName != "" {
+			sender = profile.DisplayName
+		} else if profile.RealName != "" {
+			sender = profile.RealName
+		}
+	}
+	// Messages without a sender are taken as the moderator's
+	if strings.TrimSpace(sender) == "" {
+		return nil, nil
+	}
+	recipient := RecipientEveryone
+	if event.ChannelType == "im" {
+		recipient = RecipientYou
+	}
+
+	return []Message{{Sender: sender, Recipient: recipient, Text: event.Text, Time: now}}, nil
+}
@@ -0,0 +1,82 @@
+package chat
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+const (
+	RecipientEveryone = "Everyone"
+	RecipientYou      = "You" // Direct messages to the presenter
+)
+
+const maxPayloadBytes = 1 << 20
+
+var (
+	ErrMalformedPayload  = errors.New("malformed chat payload")
+	ErrUnverifiedPayload = errors.New("unverified chat payload")
+)
+
+// Turns a chat platform's requests into messages. Requests that aren't
+// chat messages (e.g. bot messages or edits) yield no messages.
+type Source interface {
+	Messages(r *http.Request, now time.Time) ([]Message, error)
+}
+
+// Implemented by sources that can verify requests' signatures. Verified
+// requests need no other authentication.
+type VerifyingSource interface {
+	Source
+	// Reports whether every request is verified, i.e. a secret is set
+	Verifies() bool
+}
+
+// Implemented by sources whose platform expects a reply to each request,
+// rather than no content.
+type ReplyingSource interface {
+	Source
+	// JSON encoded
+	Reply(messages []Message) any
+}
+
+// Returned by sources when the platform is verifying the endpoint, which must
+// respond with Response.
+type Challenge struct {
+	Response string
+}
+
+func (c *Challenge) Error() string {
+	return "chat endpoint verification challenge"
+}
+
+// Read whole, so that signatures may be verified before decoding.
+func readPayload(r *http.Request) ([]byte, error) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxPayloadBytes))
+	if err != nil {
+		return nil, fmt.Errorf("%w (%v)", ErrMalformedPayload, err)
+	}
+
+	return body, nil
+}
+
+func decodePayload(body []byte, payload any) error {
+	if err := json.Unmarshal(body, payload); err != nil {
+		return fmt.Errorf("%w (%v)", ErrMalformedPayload, err)
+	}
+
+	return nil
+}
+
+// Sources by the name they are posted to, e.g. /chat/slack.
+func NewSourcesByName(slack SlackSource, teams TeamsSource) map[string]Source {
+	return map[string]Source{
+		"zoom":  ZoomSource{},
+		"slack": slack,
+		"teams": teams,
+		"json":  JSONSource{},
+	}
+}
@@ -0,0 +1,295 @@
+package chat
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+var testNow = time.Date(2023, 11, 14, 22, 13, 20, 0, time.UTC)
+
+func readFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	body, err := os.ReadFile(filepath.Join("..", "..", "fixtures", "chat", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return body
+}
+
+func TestSourcesWithFixtures(t *testing.T) {
+	sources := NewSourcesByName(SlackSource{}, TeamsSource{})
+	tests := []struct {
+		source  string
+		fixture string
+		want    []Message
+	}{
+		{"json", "json-message.json", []Message{
+			{Sender: "Jane Doe", Recipient: RecipientEveryone, Text: "Go and Rust", Time: testNow},
+		}},
+		{"json", "json-messages.json", []Message{
+			{Sender: "Jane Doe", Recipient: RecipientEveryone, Text: "Go and Rust", Time: testNow},
+			{Sender: "John Roe", Recipient: RecipientYou, Text: "What editor is that?", Time: testNow},
+		}},
+		{"slack", "slack-message.json", []Message{
+			{Sender: "jane", Recipient: RecipientEveryone, Text: "Go and Rust, definitely", Time: testNow},
+		}},
+		{"slack", "slack-direct-message.json", []Message{
+			{Sender: "U2147483698", Recipient: RecipientYou, Text: "Can you share the slides afterwards?", Time: testNow},
+		}},
+		{"slack", "slack-edit.json", nil},
+		{"slack", "slack-bot-message.json", nil},
+		{"slack", "slack-message-no-sender.json", nil},
+		{"teams", "teams-message.json", []Message{
+			{Sender: "Jane Doe", Recipient: RecipientEveryone, Text: "Go & Rust", Time: testNow},
+		}},
+		{"teams", "teams-message-no-sender.json", nil},
+	}
+	for _, test := range tests {
+		t.Run(test.fixture, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/chat/"+test.source, bytes.NewReader(readFixture(t, test.fixture)))
+			messages, err := sources[test.source].Messages(r, testNow)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(messages, test.want) {
+				t.Errorf("messages %+v, want %+v", messages, test.want)
+			}
+		})
+	}
+}
+
+func TestSlackURLVerification(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/chat/slack", bytes.NewReader(readFixture(t, "slack-url-verification.json")))
+	messages, err := SlackSource{}.Messages(r, testNow)
+
+	var challenge *Challenge
+	if !errors.As(err, &challenge) {
+		t.Fatalf("error %v, want challenge", err)
+	}
+	if want := "3eZbrw1aBm2rZgRNFdxV2595E9CY3gmdALWMmHkvFXO7tYXAYM8P"; challenge.Response != want {
+		t.Errorf("challenge response %q, want %q", challenge.Response, want)
+	}
+	if messages != nil {
+		t.Errorf("messages %+v, want none", messages)
+	}
+}
+
+func TestZoomSource(t *testing.T) {
+	tests := []struct {
+		query   string
+		want    []Message
+		wantErr bool
+	}{
+		{"route=Jane+Doe+to+Everyone&text=Go", []Message{
+			{Sender: "Jane Doe", Recipient: RecipientEveryone, Text: "Go", Time: testNow},
+		}, false},
+		{"route=Jane+to+Joe+to+You+(Direct+Message)&text=Hi", []Message{
+			{Sender: "Jane to Joe", Recipient: RecipientYou, Text: "Hi", Time: testNow},
+		}, false},
+		{"route=Jane+Doe&text=Go", nil, true},
+		{"route=Jane+Doe+to+Joe&text=Go", nil, true},
+	}
+	for _, test := range tests {
+		t.Run(test.query, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/chat?"+test.query, nil)
+			messages, err := ZoomSource{}.Messages(r, testNow)
+			if test.wantErr != errors.Is(err, ErrMalformedPayload) {
+				t.Fatalf("error %v, want malformed %t", err, test.wantErr)
+			}
+			if !reflect.DeepEqual(messages, test.want) {
+				t.Errorf("messages %+v, want %+v", messages, test.want)
+			}
+		})
+	}
+}
+
+func TestMalformedPayloads(t *testing.T) {
+	tests := []struct {
+		source string
+		body   string
+	}{
+		{"json", `{"text": "no sender"}`},
+		{"json", `{"sender": "Jane", "recipient": "Nobody", "text": "Go"}`},
+		{"json", `[{"sender": "Jane"}, "Go"]`},
+		{"slack", `{"type": `},
+		{"teams", `not json`},
+	}
+	sources := NewSourcesByName(SlackSource{}, TeamsSource{})
+	for _, test := range tests {
+		r := httptest.NewRequest(http.MethodPost, "/chat/"+test.source, bytes.NewReader([]byte(test.body)))
+		if _, err := sources[test.source].Messages(r, testNow); !errors.Is(err, ErrMalformedPayload) {
+			t.Errorf("%s %s: error %v, want malformed", test.source, test.body, err)
+		}
+	}
+}
+
+func TestTeamsPlainText(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"<at>Live Deck</at>&nbsp;Go &amp; Rust\n", "Go & Rust"},
+		{"<at>Live\nDeck</at> <b>Go</b><br>and <i>Rust</i>", "Go and Rust"},
+		{"Go <at>Live Deck</at> and <at>Jane</at> Rust", "Go and Rust"},
+		{"<at>Live Deck</at>", ""},
+	}
+	for _, test := range tests {
+		if text := teamsPlainText(test.text); text != test.want {
+			t.Errorf("teamsPlainText(%q) = %q, want %q", test.text, text, test.want)
+		}
+	}
+}
+
+func slackRequest(body []byte, secret string, timestamp time.Time) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, "/chat/slack", bytes.NewReader(body))
+	ts := strconv.FormatInt(timestamp.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("v0:" + ts + ":"))
+	mac.Write(body)
+	r.Header.Set("X-Slack-Request-Timestamp", ts)
+	r.Header.Set("X-Slack-Signature", "v0="+hex.EncodeToString(mac.Sum(nil)))
+
+	return r
+}
+
+func TestSlackSignature(t *testing.T) {
+	const secret = "8f742231b10e8888abcd99yyyzzz85a5"
+	body := readFixture(t, "slack-message.json")
+	source := SlackSource{SigningSecret: secret}
+	unsigned := httptest.NewRequest(http.MethodPost, "/chat/slack", bytes.NewReader(body))
+	tampered := slackRequest(body, secret, testNow)
+	tampered.Body, tampered.ContentLength = http.NoBody, 0
+
+	tests := []struct {
+		name         string
+		r            *http.Request
+		wantVerified bool
+	}{
+		{"signed", slackRequest(body, secret, testNow), true},
+		{"signed a minute ago", slackRequest(body, secret, testNow.Add(-time.Minute)), true},
+		{"unsigned", unsigned, false},
+		{"wrong secret", slackRequest(body, "not-"+secret, testNow), false},
+		{"replayed", slackRequest(body, secret, testNow.Add(-time.Hour)), false},
+		{"tampered", tampered, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			messages, err := source.Messages(test.r, testNow)
+			if verified := !errors.Is(err, ErrUnverifiedPayload); verified != test.wantVerified {
+				t.Fatalf("error %v, want verified %t", err, test.wantVerified)
+			}
+			if test.wantVerified && len(messages) != 1 {
+				t.Errorf("messages %+v, want 1", messages)
+			}
+		})
+	}
+
+	// The endpoint can't be verified without a signature either
+	r := slackRequest(readFixture(t, "slack-url-verification.json"), "not-"+secret, testNow)
+	if _, err := source.Messages(r, testNow); !errors.Is(err, ErrUnverifiedPayload) {
+		t.Errorf("error %v, want unverified", err)
+	}
+}
+
+func TestTeamsSignature(t *testing.T) {
+	key := []byte("teams outgoing webhook key")
+	securityToken := base64.StdEncoding.EncodeToString(key)
+	source, err := NewTeamsSource(securityToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := readFixture(t, "teams-message.json")
+	sign := func(key []byte) string {
+		mac := hmac.New(sha256.New, key)
+		mac.Write(body)
+		return "HMAC " + base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	}
+
+	tests := []struct {
+		name          string
+		authorization string
+		wantVerified  bool
+	}{
+		{"signed", sign(key), true},
+		{"unsigned", "", false},
+		{"wrong key", sign([]byte("another key")), false},
+		{"not base64", "HMAC !!!", false},
+		{"bearer", "Bearer " + securityToken, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/chat/teams", bytes.NewReader(body))
+			if test.authorization != "" {
+				r.Header.Set("Authorization", test.authorization)
+			}
+			messages, err := source.Messages(r, testNow)
+			if verified := !errors.Is(err, ErrUnverifiedPayload); verified != test.wantVerified {
+				t.Fatalf("error %v, want verified %t", err, test.wantVerified)
+			}
+			if test.wantVerified && len(messages) != 1 {
+				t.Errorf("messages %+v, want 1", messages)
+			}
+		})
+	}
+
+	if _, err = NewTeamsSource("not base64!"); err == nil {
+		t.Error("accepted invalid security token, want error")
+	}
+}
+
+func TestVerifies(t *testing.T) {
+	teams, err := NewTeamsSource(base64.StdEncoding.EncodeToString([]byte("key")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	unverifiedTeams, err := NewTeamsSource("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, test := range map[string]struct {
+		source VerifyingSource
+		want   bool
+	}{
+		"slack with a signing secret":    {source: SlackSource{SigningSecret: "secret"}, want: true},
+		"slack without a signing secret": {source: SlackSource{}, want: false},
+		"teams with a security token":    {source: teams, want: true},
+		"teams without a security token": {source: unverifiedTeams, want: false},
+	} {
+		if verifies := test.source.Verifies(); verifies != test.want {
+			t.Errorf("%s verifies: %t, want %t", name, verifies, test.want)
+		}
+	}
+}
+
+func TestTeamsReply(t *testing.T) {
+	source := TeamsSource{}
+	for _, test := range []struct {
+		messages []Message
+		want     string
+	}{
+		{messages: []Message{{Sender: "Jane Doe", Text: "Go"}}, want: `{"type":"message","text":"Received"}`},
+		{messages: nil, want: `{"type":"message","text":""}`},
+	} {
+		reply, err := json.Marshal(source.Reply(test.messages))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(reply) != test.want {
+			t.Errorf("reply %s, want %s", reply, test.want)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package chat
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"html"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var (
+	teamsMentionRegex = regexp.MustCompile(`(?s)<at>.*?</at>`)
+	teamsTagRegex     = regexp.MustCompile(`<[^>]*>`)
+)
+
+type teamsPayload struct {
+	Type string `json:"type"`
+	From struct {
+		Name string `json:"name"`
+	} `json:"from"`
+	Text         string `json:"text"`
+	Conversation struct {
+		ConversationType string `json:"conversationType"`
+	} `json:"conversation"`
+}
+
+// Message text is HTML, including the mention of the webhook that triggered it.
+func teamsPlainText(text string) string {
+	text = teamsMentionRegex.ReplaceAllString(text, "")
+	text = html.UnescapeString(teamsTagRegex.ReplaceAllString(text, " "))
+
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// Outgoing webhooks post the reply to the conversation.
+type teamsReply struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// Microsoft Teams outgoing webhook requests, verified if there is a key.
+type TeamsSource struct {
+	key []byte
+}
+
+// See https://learn.microsoft.com/en-us/microsoftteams/platform/webhooks-and-connectors/how-to/add-outgoing-webhook
+func (s TeamsSource) verify(r *http.Request, body []byte) error {
+	authorization := r.Header.Get("Authorization")
+	if !strings.HasPrefix(authorization, "HMAC ") {
+		return fmt.Errorf("%w: missing Teams HMAC authorization", ErrUnverifiedPayload)
+	}
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write(body)
+	signature, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(authorization, "HMAC "))
+	if err != nil || !hmac.Equal(signature, mac.Sum(nil)) {
+		return fmt.Errorf("%w: invalid Teams signature", ErrUnverifiedPayload)
+	}
+
+	return nil
+}
+
+func (s TeamsSource) Verifies() bool {
+	return len(s.key) > 0
+}
+
+// Teams shows an error in the conversation unless the webhook replies with a
+// message, so messages are acknowledged.
+func (s TeamsSource) Reply(messages []Message) any {
+	reply := teamsReply{Type: "message"}
+	if len(messages) > 0 {
+		reply.Text = "Received"
+	}
+
+	return reply
+}
+
+func (s TeamsSource) Messages(r *http.Request, now time.Time) ([]Message, error) {
+	body, err := readPayload(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(s.key) > 0 {
+		if err = s.verify(r, body); err != nil {
+			return nil, err
+		}
+	}
+	var payload teamsPayload
+	if err = decodePayload(body, &payload); err != nil {
+		return nil, err
+	}
+	text := teamsPlainText(payload.Text)
+	// Messages without a sender are taken as the moderator's
+	if payload.Type != "message" || text == "" || strings.TrimSpace(payload.From.Name) == "" {
+		return nil, nil
+	}
+
+	recipient := RecipientEveryone
+	if payload.Conversation.ConversationType == "personal" {
+		recipient = RecipientYou
+	}
+
+	return []Message{{Sender: payload.From.Name, Recipient: recipient, Text: text, Time: now}}, nil
+}
+
+// Verifies requests with securityToken, the base64 key Teams shows when the
+// webhook is created, unless it is empty.
+func NewTeamsSource(securityToken string) (TeamsSource, error) {
+	key, err := base64.StdEncoding.DecodeString(securityToken)
+	if err != nil {
+		return TeamsSource{}, fmt.Errorf("invalid Teams security token (%w)", err)
+	}
+
+	return TeamsSource{key: key}, nil
+}
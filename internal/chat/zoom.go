@@ -0,0 +1,41 @@
+package chat
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const zoomRouteSeparator = " to "
+
+var zoomRecipients = map[string]string{
+	"Everyone":             RecipientEveryone,
+	"You":                  RecipientYou,
+	"You (Direct Message)": RecipientYou,
+}
+
+// Zoom chat, relayed as query parameters, with the sender and recipient in a
+// route, e.g. "Jane Doe to Everyone".
+type ZoomSource struct{}
+
+func (ZoomSource) Messages(r *http.Request, now time.Time) ([]Message, error) {
+	query := r.URL.Query()
+	route := query.Get("route")
+	sepIdx := strings.LastIndex(route, zoomRouteSeparator)
+	if sepIdx == -1 {
+		return nil, fmt.Errorf("%w: malformed route", ErrMalformedPayload)
+	}
+
+	recipient, ok := zoomRecipients[route[sepIdx+len(zoomRouteSeparator):]]
+	if !ok {
+		return nil, fmt.Errorf("%w: invalid recipient", ErrMalformedPayload)
+	}
+
+	return []Message{{
+		Sender:    route[:sepIdx],
+		Recipient: recipient,
+		Text:      query.Get("text"),
+		Time:      now,
+	}}, nil
+}
@@ -0,0 +1,48 @@
+package config
+
+import (
+	"fmt"
+	"presentation-service/internal/auth"
+	"time"
+)
+
+const defaultSessionTTL = 12 * time.Hour
+
+type Auth struct {
+	// Shared token for each role - authentication is disabled if there are none
+	Tokens map[auth.Role]string `json:"tokens,omitempty"`
+	// Signs session cookies - if empty, sessions end when the server restarts
+	SessionSecret string `json:"sessionSecret,omitempty"`
+	SessionTTL    string `json:"sessionTtl,omitempty"` // Default "12h"
+}
+
+func (a Auth) SessionDuration() (time.Duration, error) {
+	ttl, err := parseOptionalDuration(a.SessionTTL)
+	if err != nil || ttl < 0 {
+		return 0, fmt.Errorf(`invalid auth sessionTtl "%s"`, a.SessionTTL)
+	}
+	if ttl == 0 {
+		ttl = defaultSessionTTL
+	}
+
+	return ttl, nil
+}
+
+func (a Auth) validate() error {
+	roles := make(map[string]auth.Role, len(a.Tokens))
+	for role, token := range a.Tokens {
+		if !role.Valid() || role == auth.RoleAudience {
+			return fmt.Errorf(`invalid auth role "%s"`, role)
+		}
+		if len(token) < 16 {
+			return fmt.Errorf(`auth token for "%s" must be at least 16 characters`, role)
+		}
+		if other, duplicate := roles[token]; duplicate {
+			return fmt.Errorf(`auth roles "%s" and "%s" share a token`, other, role)
+		}
+		roles[token] = role
+	}
+	_, err := a.SessionDuration()
+
+	return err
+}
@@ -0,0 +1,27 @@
+package config
+
+import (
+	"presentation-service/internal/chat"
+)
+
+// Secrets verifying chat platforms' requests, if set.
+type Chat struct {
+	SlackSigningSecret string `json:"slackSigningSecret,omitempty"`
+	// The base64 token shown when a Teams outgoing webhook is created
+	TeamsSecurityToken string `json:"teamsSecurityToken,omitempty"`
+}
+
+func (c Chat) SourcesByName() (map[string]chat.Source, error) {
+	teams, err := chat.NewTeamsSource(c.TeamsSecurityToken)
+	if err != nil {
+		return nil, err
+	}
+
+	return chat.NewSourcesByName(chat.SlackSource{SigningSecret: c.SlackSigningSecret}, teams), nil
+}
+
+func (c Chat) validate() error {
+	_, err := c.SourcesByName()
+
+	return err
+}
@@ -0,0 +1,217 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"presentation-service/internal/chat/counter"
+	"presentation-service/internal/chat/moderation"
+	"presentation-service/internal/token"
+	"regexp"
+	"time"
+)
+
+const defaultPollInitialCapacity = 200
+
+var pollNameRegex = regexp.MustCompile("^[a-z0-9][a-z0-9-]*$")
+
+type Poll struct {
+	Name            string `json:"name"`
+	TokensPerSender int    `json:"tokensPerSender"`
+	// Report only the top N tokens, combining the rest as "Other" (optional)
+	TopN int `json:"topN,omitempty"`
+	Extraction
+	InitialCapacity int `json:"initialCapacity,omitempty"`
+	// Only count votes from the last Window, or decay votes with a HalfLife
+	// (e.g. "5m") - counts are cumulative if neither are set
+	Window   string `json:"window,omitempty"`
+	HalfLife string `json:"halfLife,omitempty"`
+	// "plurality" (default), "weighted" by preference, or "instant-runoff"
+	Voting  counter.VotingMode `json:"voting,omitempty"`
+	Weights []float64          `json:"weights,omitempty"` // For each preference, if weighted
+	// Initial state, "open" if empty - scheduled polls are opened by the presenter
+	State           counter.State `json:"state,omitempty"`
+	HideUntilReveal bool          `json:"hideUntilReveal,omitempty"`
+}
+
+func parseOptionalDuration(duration string) (time.Duration, error) {
+	if duration == "" {
+		return 0, nil
+	}
+
+	return time.ParseDuration(duration)
+}
+
+func (p Poll) Counting() (counter.Counting, error) {
+	window, err := parseOptionalDuration(p.Window)
+	if err != nil {
+		return counter.Counting{}, fmt.Errorf(`poll "%s" has invalid window (%w)`, p.Name, err)
+	}
+	halfLife, err := parseOptionalDuration(p.HalfLife)
+	if err != nil {
+		return counter.Counting{}, fmt.Errorf(`poll "%s" has invalid halfLife (%w)`, p.Name, err)
+	}
+	if window < 0 || halfLife < 0 || (window > 0 && halfLife > 0) {
+		return counter.Counting{}, fmt.Errorf(
+			`poll "%s" requires at most one of a positive window or halfLife`, p.Name,
+		)
+	}
+
+	return counter.Counting{Window: window, HalfLife: halfLife}, nil
+}
+
+func (p Poll) VotingOptions() counter.Voting {
+	return counter.Voting{Mode: p.Voting, Weights: p.Weights}
+}
+
+func (p Poll) Lifecycle() counter.Lifecycle {
+	return counter.Lifecycle{InitialState: p.State, HideUntilReveal: p.HideUntilReveal}
+}
+
+// Rating polls report statistics on the scale, other polls return nil.
+func (p Poll) RatingScale() *token.RatingScale {
+	if p.Scale != nil {
+		return p.Scale
+	}
+	if p.Extractor == token.RatingExtractorName {
+		return &token.DefaultRatingScale
+	}
+
+	return nil
+}
+
+func (p Poll) ExtractTokens(
+	vocabularies *token.VocabularyRegistry, onFuzzyMatch func(token.FuzzyMatch),
+) (func(string) []string, error) {
+	return p.Extraction.extractTokens(fmt.Sprintf(`poll "%s"`, p.Name), vocabularies, onFuzzyMatch)
+}
+
+func (p Poll) validate() error {
+	if !pollNameRegex.MatchString(p.Name) {
+		return fmt.Errorf(`invalid poll name "%s"`, p.Name)
+	}
+	if _, err := p.Counting(); err != nil {
+		return err
+	}
+	if p.State != "" && !p.State.Valid() {
+		return fmt.Errorf(`poll "%s" has invalid state "%s"`, p.Name, p.State)
+	}
+	if p.Voting != "" && !p.Voting.Valid() {
+		return fmt.Errorf(`poll "%s" has invalid voting "%s"`, p.Name, p.Voting)
+	}
+	if len(p.Weights) > 0 && p.Voting != counter.VotingWeighted {
+		return fmt.Errorf(`poll "%s" weights require "%s" voting`, p.Name, counter.VotingWeighted)
+	}
+	for _, weight := range p.Weights {
+		if weight <= 0 {
+			return fmt.Errorf(`poll "%s" weights must be positive`, p.Name)
+		}
+	}
+	if p.HalfLife != "" && (p.Voting == counter.VotingWeighted || p.Voting == counter.VotingInstantRunoff) {
+		return fmt.Errorf(`poll "%s" halfLife is only supported with "%s" voting`, p.Name, counter.VotingPlurality)
+	}
+	if p.TopN < 0 {
+		return fmt.Errorf(`poll "%s" topN must not be negative`, p.Name)
+	}
+	if p.TokensPerSender < 1 {
+		return fmt.Errorf(`poll "%s" tokensPerSender must be at least 1`, p.Name)
+	}
+	if err := p.Extraction.validate(fmt.Sprintf(`poll "%s"`, p.Name)); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+type Questions struct {
+	// How question senders are shown on the presentation
+	Attribution moderation.Attribution `json:"attribution"`
+}
+
+type Config struct {
+	Polls     []Poll    `json:"polls"`
+	Questions Questions `json:"questions"`
+	Quizzes   []Quiz    `json:"quizzes,omitempty"`
+	Auth      Auth      `json:"auth"`
+	Chat      Chat      `json:"chat"`
+	// Optional IRC channel to receive chat from
+	IRC *IRC `json:"irc,omitempty"`
+}
+
+func (c Config) validate() error {
+	if err := c.Auth.validate(); err != nil {
+		return err
+	}
+	if err := c.Chat.validate(); err != nil {
+		return err
+	}
+	if c.IRC != nil {
+		if err := c.IRC.validate(); err != nil {
+			return err
+		}
+	}
+	if !c.Questions.Attribution.Valid() {
+		return fmt.Errorf(`invalid question attribution "%s"`, c.Questions.Attribution)
+	}
+	names := make(map[string]struct{}, len(c.Polls))
+	for _, poll := range c.Polls {
+		if err := poll.validate(); err != nil {
+			return err
+		}
+		if _, duplicate := names[poll.Name]; duplicate {
+			return fmt.Errorf(`duplicate poll name "%s"`, poll.Name)
+		}
+		names[poll.Name] = struct{}{}
+	}
+	quizNames := make(map[string]struct{}, len(c.Quizzes))
+	for _, quiz := range c.Quizzes {
+		if err := quiz.validate(); err != nil {
+			return err
+		}
+		if _, duplicate := quizNames[quiz.Name]; duplicate {
+			return fmt.Errorf(`duplicate quiz name "%s"`, quiz.Name)
+		}
+		quizNames[quiz.Name] = struct{}{}
+	}
+
+	return nil
+}
+
+func Default() Config {
+	return Config{
+		Polls: []Poll{
+			{
+				Name: "language-poll", TokensPerSender: 3, Extraction: Extraction{Extractor: "languages"},
+				InitialCapacity: defaultPollInitialCapacity,
+			},
+		},
+		Questions: Questions{Attribution: moderation.AttributionAnonymous},
+	}
+}
+
+func Load(path string) (Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, err
+	}
+
+	config := Config{Questions: Default().Questions}
+	if err = json.Unmarshal(data, &config); err != nil {
+		return Config{}, fmt.Errorf("malformed config %s (%w)", path, err)
+	}
+	if err = config.validate(); err != nil {
+		return Config{}, err
+	}
+	for i := range config.Polls {
+		if config.Polls[i].InitialCapacity <= 0 {
+			config.Polls[i].InitialCapacity = defaultPollInitialCapacity
+		}
+	}
+	for i := range config.Quizzes {
+		if config.Quizzes[i].LeaderboardSize == 0 {
+			config.Quizzes[i].LeaderboardSize = defaultQuizLeaderboardSize
+		}
+	}
+
+	return config, nil
+}
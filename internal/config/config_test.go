@@ -0,0 +1,150 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"presentation-service/internal/chat/moderation"
+	"presentation-service/internal/token"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestDefaultIsValid(t *testing.T) {
+	if err := Default().validate(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestLoadDefaults(t *testing.T) {
+	config, err := Load(writeConfig(t, `{
+		"polls": [{"name": "editor-poll", "tokensPerSender": 1, "vocabulary": {"vim": "Vim"}}],
+		"quizzes": [{"name": "go-quiz", "extractor": "choice", "questions": [{"text": "Which?", "answer": "A"}]}]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if config.Questions.Attribution != moderation.AttributionAnonymous {
+		t.Errorf("attribution %s, want anonymous", config.Questions.Attribution)
+	}
+	if capacity := config.Polls[0].InitialCapacity; capacity != defaultPollInitialCapacity {
+		t.Errorf("initial capacity %d, want %d", capacity, defaultPollInitialCapacity)
+	}
+	if size := config.Quizzes[0].LeaderboardSize; size != defaultQuizLeaderboardSize {
+		t.Errorf("leaderboard size %d, want %d", size, defaultQuizLeaderboardSize)
+	}
+	questions, err := config.Quizzes[0].QuizQuestions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if questions[0].TimeLimit != defaultQuizTimeLimit || questions[0].Points != defaultQuizPoints {
+		t.Errorf("question %+v, want the default time limit and points", questions[0])
+	}
+	if ttl, err := config.Auth.SessionDuration(); err != nil || ttl != defaultSessionTTL {
+		t.Errorf("session TTL %v (%v), want %v", ttl, err, defaultSessionTTL)
+	}
+	if config.IRC != nil {
+		t.Errorf("IRC %+v, want none", config.IRC)
+	}
+}
+
+func TestLoadRejectsInvalidConfig(t *testing.T) {
+	const poll = `{"name": "editor-poll", "tokensPerSender": 1, "vocabulary": {"vim": "Vim"}}`
+	const quiz = `{"name": "go-quiz", "extractor": "choice", "questions": [{"text": "Which?", "answer": "A"}]}`
+	for _, test := range []struct {
+		name    string
+		config  string
+		wantErr string
+	}{
+		{name: "malformed", config: `{"polls": {}}`, wantErr: "malformed config"},
+		{name: "duplicate poll names", config: `{"polls": [` + poll + `, ` + poll + `]}`, wantErr: `duplicate poll name "editor-poll"`},
+		{name: "duplicate quiz names", config: `{"quizzes": [` + quiz + `, ` + quiz + `]}`, wantErr: `duplicate quiz name "go-quiz"`},
+		{name: "poll name", config: `{"polls": [{"name": "Editor Poll", "tokensPerSender": 1, "extractor": "languages"}]}`, wantErr: "invalid poll name"},
+		{name: "poll tokensPerSender", config: `{"polls": [{"name": "p", "extractor": "languages"}]}`, wantErr: "tokensPerSender must be at least 1"},
+		{name: "poll topN", config: `{"polls": [{"name": "p", "tokensPerSender": 1, "topN": -1, "extractor": "languages"}]}`, wantErr: "topN must not be negative"},
+		{name: "poll window and halfLife", config: `{"polls": [{"name": "p", "tokensPerSender": 1, "window": "5m", "halfLife": "1m", "extractor": "languages"}]}`, wantErr: "at most one of a positive window or halfLife"},
+		{name: "poll window", config: `{"polls": [{"name": "p", "tokensPerSender": 1, "window": "soon", "extractor": "languages"}]}`, wantErr: "invalid window"},
+		{name: "poll state", config: `{"polls": [{"name": "p", "tokensPerSender": 1, "state": "finished", "extractor": "languages"}]}`, wantErr: `invalid state "finished"`},
+		{name: "poll voting", config: `{"polls": [{"name": "p", "tokensPerSender": 1, "voting": "approval", "extractor": "languages"}]}`, wantErr: `invalid voting "approval"`},
+		{name: "poll weights without weighted voting", config: `{"polls": [{"name": "p", "tokensPerSender": 2, "weights": [2, 1], "extractor": "languages"}]}`, wantErr: `weights require "weighted" voting`},
+		{name: "poll weights", config: `{"polls": [{"name": "p", "tokensPerSender": 2, "voting": "weighted", "weights": [1, 0], "extractor": "languages"}]}`, wantErr: "weights must be positive"},
+		{name: "poll halfLife with ranked voting", config: `{"polls": [{"name": "p", "tokensPerSender": 2, "voting": "instant-runoff", "halfLife": "1m", "extractor": "languages"}]}`, wantErr: "halfLife is only supported"},
+		{name: "extractor and vocabulary", config: `{"polls": [{"name": "p", "tokensPerSender": 1, "extractor": "languages", "vocabulary": {"vim": "Vim"}}]}`, wantErr: "exactly one of extractor or vocabulary"},
+		{name: "no extractor or vocabulary", config: `{"polls": [{"name": "p", "tokensPerSender": 1}]}`, wantErr: "exactly one of extractor or vocabulary"},
+		{name: "choices", config: `{"polls": [{"name": "p", "tokensPerSender": 1, "extractor": "choice", "choices": 27}]}`, wantErr: "choices must be 2 to 26"},
+		{name: "scale", config: `{"polls": [{"name": "p", "tokensPerSender": 1, "extractor": "rating", "scale": {"min": 5, "max": 1}}]}`, wantErr: "scale must span"},
+		{name: "wordCloud extractor", config: `{"polls": [{"name": "p", "tokensPerSender": 1, "extractor": "languages", "wordCloud": {}}]}`, wantErr: `wordCloud requires the "word-cloud" extractor`},
+		{name: "wordCloud", config: `{"polls": [{"name": "p", "tokensPerSender": 1, "extractor": "word-cloud", "wordCloud": {"language": "klingon"}}]}`, wantErr: "invalid wordCloud"},
+		{name: "fuzzy", config: `{"polls": [{"name": "p", "tokensPerSender": 1, "extractor": "languages", "fuzzy": {"maxDistance": 0}}]}`, wantErr: "invalid fuzzy"},
+		{name: "fuzzy choice", config: `{"polls": [{"name": "p", "tokensPerSender": 1, "extractor": "choice", "choices": 3, "fuzzy": {"maxDistance": 1}}]}`, wantErr: "fuzzy matching is only supported for vocabularies"},
+		{name: "vocabulary", config: `{"polls": [{"name": "p", "tokensPerSender": 1, "vocabulary": {"vim": ""}}]}`, wantErr: "invalid vocabulary"},
+		{name: "quiz name", config: `{"quizzes": [{"name": "", "extractor": "choice", "questions": [{"answer": "A"}]}]}`, wantErr: "invalid quiz name"},
+		{name: "quiz without questions", config: `{"quizzes": [{"name": "q", "extractor": "choice"}]}`, wantErr: "has no questions"},
+		{name: "quiz answer", config: `{"quizzes": [{"name": "q", "extractor": "choice", "questions": [{"text": "Which?"}]}]}`, wantErr: "question 1 has no answer"},
+		{name: "quiz points", config: `{"quizzes": [{"name": "q", "extractor": "choice", "questions": [{"answer": "A", "points": -1}]}]}`, wantErr: "points must not be negative"},
+		{name: "quiz timeLimit", config: `{"quizzes": [{"name": "q", "extractor": "choice", "questions": [{"answer": "A", "timeLimit": "-1s"}]}]}`, wantErr: "invalid timeLimit"},
+		{name: "quiz leaderboardSize", config: `{"quizzes": [{"name": "q", "extractor": "choice", "leaderboardSize": -1, "questions": [{"answer": "A"}]}]}`, wantErr: "leaderboardSize must not be negative"},
+		{name: "quiz fuzzy", config: `{"quizzes": [{"name": "q", "extractor": "languages", "fuzzy": {"maxDistance": 1}, "questions": [{"answer": "Go"}]}]}`, wantErr: "does not support fuzzy matching"},
+		{name: "attribution", config: `{"questions": {"attribution": "emoji"}}`, wantErr: `invalid question attribution "emoji"`},
+		{name: "auth role", config: `{"auth": {"tokens": {"audience": "0123456789abcdef"}}}`, wantErr: `invalid auth role "audience"`},
+		{name: "auth token length", config: `{"auth": {"tokens": {"admin": "short"}}}`, wantErr: "at least 16 characters"},
+		{name: "auth shared token", config: `{"auth": {"tokens": {"admin": "0123456789abcdef", "presenter": "0123456789abcdef"}}}`, wantErr: "share a token"},
+		{name: "auth sessionTtl", config: `{"auth": {"sessionTtl": "forever"}}`, wantErr: `invalid auth sessionTtl "forever"`},
+		{name: "chat teamsSecurityToken", config: `{"chat": {"teamsSecurityToken": "not base64!"}}`, wantErr: "invalid Teams security token"},
+		{name: "irc server", config: `{"irc": {"server": "irc.example.com", "nick": "bot", "channel": "#go"}}`, wantErr: "invalid irc server"},
+		{name: "irc nick", config: `{"irc": {"server": "irc.example.com:6697", "nick": "a bot", "channel": "#go"}}`, wantErr: "invalid irc nick"},
+		{name: "irc channel", config: `{"irc": {"server": "irc.example.com:6697", "nick": "bot", "channel": "go"}}`, wantErr: "invalid irc channel"},
+		{name: "irc password", config: `{"irc": {"server": "irc.example.com:6697", "nick": "bot", "channel": "#go", "password": "a\r\nQUIT"}}`, wantErr: "invalid irc password"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := Load(writeConfig(t, test.config))
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("error %v, want %q", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestExtractTokensRejectsUnknownExtractors(t *testing.T) {
+	for _, poll := range []Poll{
+		{Name: "p", Extraction: Extraction{Extractor: "editors"}},
+		{Name: "p", Extraction: Extraction{Extractor: "choice", Fuzzy: &token.FuzzyOptions{MaxDistance: 1}}},
+	} {
+		if _, err := poll.ExtractTokens(nil, nil); err == nil || !strings.Contains(err.Error(), "unknown extractor") {
+			t.Errorf("extractor %s returned %v, want unknown extractor", poll.Extractor, err)
+		}
+	}
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "editors.json"), []byte(`{"vim": "Vim"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	vocabularies, err := token.NewVocabularyRegistry(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	poll := Poll{Name: "p", Extraction: Extraction{Extractor: "editors"}}
+	if _, err = poll.ExtractTokens(vocabularies, nil); err != nil {
+		t.Errorf("loaded vocabulary rejected (%v)", err)
+	}
+}
+
+func TestSessionDuration(t *testing.T) {
+	if ttl, err := (Auth{SessionTTL: "30m"}).SessionDuration(); err != nil || ttl != 30*time.Minute {
+		t.Errorf("session TTL %v (%v), want 30m", ttl, err)
+	}
+	if _, err := (Auth{SessionTTL: "-1h"}).SessionDuration(); err == nil {
+		t.Error("negative session TTL accepted")
+	}
+}
@@ -0,0 +1,85 @@
+package config
+
+import (
+	"fmt"
+	"presentation-service/internal/token"
+)
+
+// How tokens are extracted from chat messages, by polls and quizzes.
+type Extraction struct {
+	// Either the name of an extractor or vocabulary file, or an alias to token vocabulary
+	Extractor  string              `json:"extractor,omitempty"`
+	Vocabulary map[string]string   `json:"vocabulary,omitempty"`
+	Fuzzy      *token.FuzzyOptions `json:"fuzzy,omitempty"`
+	// Number of answers for the "choice" extractor, the "rating" extractor
+	// scale, or "word-cloud" extractor options
+	Choices   int                     `json:"choices,omitempty"`
+	Scale     *token.RatingScale      `json:"scale,omitempty"`
+	WordCloud *token.WordCloudOptions `json:"wordCloud,omitempty"`
+}
+
+// subject names the poll or quiz in errors, e.g. `poll "editor"`.
+func (e Extraction) extractTokens(
+	subject string, vocabularies *token.VocabularyRegistry, onFuzzyMatch func(token.FuzzyMatch),
+) (func(string) []string, error) {
+	if e.Choices > 0 {
+		return token.NewChoiceExtractor(e.Choices), nil
+	}
+	if e.Scale != nil {
+		return token.NewRatingExtractor(*e.Scale), nil
+	}
+	if e.WordCloud != nil {
+		return token.NewWordCloudExtractor(*e.WordCloud), nil
+	}
+	if e.Extractor != "" {
+		extractor, ok := vocabularies.Extractor(e.Extractor, e.Fuzzy, onFuzzyMatch)
+		if !ok {
+			return nil, fmt.Errorf(
+				`%s has unknown extractor "%s" (fuzzy matching is only supported for vocabularies)`,
+				subject, e.Extractor,
+			)
+		}
+		return extractor, nil
+	}
+	if e.Fuzzy != nil {
+		return token.NewFuzzyVocabularyExtractor(e.Vocabulary, *e.Fuzzy, onFuzzyMatch), nil
+	}
+
+	return token.NewVocabularyExtractor(e.Vocabulary), nil
+}
+
+func (e Extraction) validate(subject string) error {
+	if (e.Extractor == "") == (len(e.Vocabulary) == 0) {
+		return fmt.Errorf(`%s requires exactly one of extractor or vocabulary`, subject)
+	}
+	if e.Choices != 0 && (e.Extractor != token.ChoiceExtractorName || e.Choices < 2 || e.Choices > 26) {
+		return fmt.Errorf(`%s choices must be 2 to 26, with the "%s" extractor`, subject, token.ChoiceExtractorName)
+	}
+	if e.Scale != nil && (e.Extractor != token.RatingExtractorName || !e.Scale.Valid()) {
+		return fmt.Errorf(
+			`%s scale must span at most 100 non-negative ratings, with the "%s" extractor`,
+			subject, token.RatingExtractorName,
+		)
+	}
+	if e.WordCloud != nil {
+		if e.Extractor != token.WordCloudExtractorName {
+			return fmt.Errorf(`%s wordCloud requires the "%s" extractor`, subject, token.WordCloudExtractorName)
+		}
+		if err := e.WordCloud.Validate(); err != nil {
+			return fmt.Errorf(`%s has invalid wordCloud (%w)`, subject, err)
+		}
+	}
+	if e.Fuzzy != nil && (e.Choices != 0 || e.Scale != nil || e.WordCloud != nil) {
+		return fmt.Errorf(`%s fuzzy matching is only supported for vocabularies`, subject)
+	}
+	if e.Fuzzy != nil {
+		if err := e.Fuzzy.Validate(); err != nil {
+			return fmt.Errorf(`%s has invalid fuzzy (%w)`, subject, err)
+		}
+	}
+	if err := token.ValidateVocabulary(e.Vocabulary); err != nil {
+		return fmt.Errorf(`%s has invalid vocabulary (%w)`, subject, err)
+	}
+
+	return nil
+}
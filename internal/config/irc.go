@@ -0,0 +1,39 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"presentation-service/internal/chat/irc"
+	"strings"
+)
+
+type IRC struct {
+	Server string `json:"server"` // host:port, e.g. "irc.chat.twitch.tv:6697"
+	TLS    bool   `json:"tls,omitempty"`
+	// Twitch allows anonymous reads with a nick like "justinfan12345"
+	Nick     string `json:"nick"`
+	Password string `json:"password,omitempty"`
+	Channel  string `json:"channel"`
+}
+
+func (i IRC) Options() irc.Options {
+	return irc.Options{Addr: i.Server, TLS: i.TLS, Nick: i.Nick, Password: i.Password, Channel: i.Channel}
+}
+
+func (i IRC) validate() error {
+	if _, _, err := net.SplitHostPort(i.Server); err != nil {
+		return fmt.Errorf(`invalid irc server "%s", expected host:port`, i.Server)
+	}
+	if i.Nick == "" || strings.ContainsAny(i.Nick, " \r\n") {
+		return fmt.Errorf(`invalid irc nick "%s"`, i.Nick)
+	}
+	if !strings.HasPrefix(i.Channel, "#") && !strings.HasPrefix(i.Channel, "&") ||
+		strings.ContainsAny(i.Channel, " ,\a\r\n") {
+		return fmt.Errorf(`invalid irc channel "%s"`, i.Channel)
+	}
+	if strings.ContainsAny(i.Password, "\r\n") {
+		return fmt.Errorf("invalid irc password")
+	}
+
+	return nil
+}
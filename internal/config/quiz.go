@@ -0,0 +1,87 @@
+package config
+
+import (
+	"fmt"
+	"presentation-service/internal/chat/quiz"
+	"presentation-service/internal/token"
+	"time"
+)
+
+const (
+	defaultQuizTimeLimit       = 30 * time.Second
+	defaultQuizPoints          = 100
+	defaultQuizLeaderboardSize = 10
+)
+
+type QuizQuestion struct {
+	Text string `json:"text"`
+	// Correct token, as extracted (e.g. "B" with the "choice" extractor)
+	Answer    string `json:"answer"`
+	TimeLimit string `json:"timeLimit,omitempty"` // Default "30s"
+	Points    int    `json:"points,omitempty"`    // Default 100
+}
+
+type Quiz struct {
+	Name string `json:"name"`
+	Extraction
+	Questions       []QuizQuestion `json:"questions"`
+	LeaderboardSize int            `json:"leaderboardSize,omitempty"` // Default 10
+}
+
+func (q Quiz) subject() string {
+	return fmt.Sprintf(`quiz "%s"`, q.Name)
+}
+
+func (q Quiz) QuizQuestions() ([]quiz.Question, error) {
+	questions := make([]quiz.Question, 0, len(q.Questions))
+	for i, question := range q.Questions {
+		timeLimit, err := parseOptionalDuration(question.TimeLimit)
+		if err != nil || timeLimit < 0 {
+			return nil, fmt.Errorf(`%s question %d has invalid timeLimit "%s"`, q.subject(), i+1, question.TimeLimit)
+		}
+		if timeLimit == 0 {
+			timeLimit = defaultQuizTimeLimit
+		}
+		points := question.Points
+		if points == 0 {
+			points = defaultQuizPoints
+		}
+		questions = append(questions, quiz.Question{
+			Text: question.Text, Answer: question.Answer, TimeLimit: timeLimit, Points: points,
+		})
+	}
+
+	return questions, nil
+}
+
+func (q Quiz) ExtractTokens(vocabularies *token.VocabularyRegistry) (func(string) []string, error) {
+	return q.Extraction.extractTokens(q.subject(), vocabularies, nil)
+}
+
+func (q Quiz) validate() error {
+	if !pollNameRegex.MatchString(q.Name) {
+		return fmt.Errorf(`invalid quiz name "%s"`, q.Name)
+	}
+	if len(q.Questions) == 0 {
+		return fmt.Errorf(`%s has no questions`, q.subject())
+	}
+	for i, question := range q.Questions {
+		if question.Answer == "" {
+			return fmt.Errorf(`%s question %d has no answer`, q.subject(), i+1)
+		}
+		if question.Points < 0 {
+			return fmt.Errorf(`%s question %d points must not be negative`, q.subject(), i+1)
+		}
+	}
+	if _, err := q.QuizQuestions(); err != nil {
+		return err
+	}
+	if q.LeaderboardSize < 0 {
+		return fmt.Errorf(`%s leaderboardSize must not be negative`, q.subject())
+	}
+	if q.Fuzzy != nil {
+		return fmt.Errorf(`%s does not support fuzzy matching`, q.subject())
+	}
+
+	return q.Extraction.validate(q.subject())
+}
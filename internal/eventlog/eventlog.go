@@ -0,0 +1,138 @@
+package eventlog
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"presentation-service/internal/chat"
+	"presentation-service/internal/notification"
+	"sync"
+	"time"
+)
+
+// Append-only JSON lines log of chat, rejected, transcription and reset events.
+type Log struct {
+	path  string
+	file  *os.File
+	mutex sync.Mutex
+}
+
+func (l *Log) Append(event Event) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	_, err = l.file.Write(line)
+
+	return err
+}
+
+func (l *Log) appendOrLog(event Event) {
+	if err := l.Append(event); err != nil {
+		log.Printf("error appending %s event to %s (%v)", event.Kind, l.path, err)
+	}
+}
+
+// Buffered before blocking the broadcaster, so that no message goes unrecorded.
+const recordBufferSize = 1024
+
+// Appends every message from broadcaster as kind, until ctx is cancelled.
+func (l *Log) Record(ctx context.Context, kind Kind, broadcaster *chat.Broadcaster) error {
+	messages, err := broadcaster.Subscribe(ctx, notification.Block(recordBufferSize))
+	if err != nil {
+		return err
+	}
+	go func() {
+		for msg := range messages {
+			message := msg
+			l.appendOrLog(Event{Time: time.Now(), Kind: kind, Message: &message})
+		}
+	}()
+
+	return nil
+}
+
+// Calls handle for each event, in the order they were appended.
+// A truncated final line, from a crash mid-write, is ignored.
+func (l *Log) Replay(handle func(Event)) (int, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	file, err := os.Open(l.path)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = file.Close() }()
+
+	reader := bufio.NewReader(file)
+	numEvents := 0
+	for {
+		line, readErr := reader.ReadBytes('\n')
+		if errors.Is(readErr, io.EOF) {
+			if len(line) > 0 {
+				log.Printf("ignoring truncated event at end of %s", l.path)
+			}
+			return numEvents, nil
+		}
+		if readErr != nil {
+			return numEvents, readErr
+		}
+
+		var event Event
+		if decodeErr := json.Unmarshal(line, &event); decodeErr != nil {
+			log.Printf("skipping malformed event in %s (%v)", l.path, decodeErr)
+			continue
+		}
+		handle(event)
+		numEvents++
+	}
+}
+
+func (l *Log) Close() error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	return l.file.Close()
+}
+
+// Terminates any truncated final line, so that new events are not appended to it.
+func terminateLastLine(path string) error {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = file.Close() }()
+
+	info, err := file.Stat()
+	if err != nil || info.Size() == 0 {
+		return err
+	}
+	lastByte := make([]byte, 1)
+	if _, err = file.ReadAt(lastByte, info.Size()-1); err != nil {
+		return err
+	}
+	if lastByte[0] != '\n' {
+		_, err = file.WriteAt([]byte{'\n'}, info.Size())
+	}
+
+	return err
+}
+
+func Open(path string) (*Log, error) {
+	if err := terminateLastLine(path); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Log{path: path, file: file}, nil
+}
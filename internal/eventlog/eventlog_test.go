@@ -0,0 +1,174 @@
+package eventlog
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"presentation-service/internal/chat"
+	"presentation-service/internal/chat/moderation"
+	"testing"
+	"time"
+)
+
+func openTestLog(t *testing.T, path string) *Log {
+	t.Helper()
+	eventLog, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = eventLog.Close() })
+
+	return eventLog
+}
+
+func replayAll(t *testing.T, eventLog *Log) []Event {
+	t.Helper()
+	var events []Event
+	if _, err := eventLog.Replay(func(event Event) { events = append(events, event) }); err != nil {
+		t.Fatal(err)
+	}
+
+	return events
+}
+
+func TestReplayInAppendOrder(t *testing.T) {
+	eventLog := openTestLog(t, filepath.Join(t.TempDir(), "events.jsonl"))
+	appended := []Event{
+		ChatEvent(chat.Message{Sender: "Jane", Recipient: "Everyone", Text: "Go"}),
+		TranscriptionEvent("hello"),
+		ModerationEvent(moderation.Command{Action: moderation.ActionApprove, ID: 3}),
+		ResetEvent(),
+	}
+	for _, event := range appended {
+		if err := eventLog.Append(event); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	events := replayAll(t, eventLog)
+
+	if len(events) != len(appended) {
+		t.Fatalf("replayed %d events, want %d", len(events), len(appended))
+	}
+	for i, event := range events {
+		if event.Kind != appended[i].Kind {
+			t.Errorf("event %d is %s, want %s", i, event.Kind, appended[i].Kind)
+		}
+	}
+	if events[0].Message == nil || events[0].Message.Text != "Go" {
+		t.Errorf("replayed chat %+v, want text Go", events[0].Message)
+	}
+	if events[2].Command == nil || events[2].Command.ID != 3 {
+		t.Errorf("replayed moderation %+v, want question 3", events[2].Command)
+	}
+}
+
+func TestReplayIgnoresTruncatedTailAndMalformedLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	contents := `{"ts":"2024-01-01T00:00:00Z","k":"transcription","t":"one"}` + "\n" +
+		"not json\n" +
+		`{"ts":"2024-01-01T00:00:01Z","k":"transcription","t":"two"}` + "\n" +
+		`{"ts":"2024-01-01T00:00:02Z","k":"transcr`
+	if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+	eventLog := openTestLog(t, path)
+
+	events := replayAll(t, eventLog)
+
+	if len(events) != 2 || events[0].Text != "one" || events[1].Text != "two" {
+		t.Errorf("replayed %+v, want transcriptions one and two", events)
+	}
+}
+
+func TestAppendAfterTruncatedTail(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	contents := `{"ts":"2024-01-01T00:00:00Z","k":"transcription","t":"one"}` + "\n" + `{"ts":"2024-`
+	if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+	eventLog := openTestLog(t, path)
+
+	if err := eventLog.Append(TranscriptionEvent("two")); err != nil {
+		t.Fatal(err)
+	}
+	events := replayAll(t, eventLog)
+
+	if len(events) != 2 || events[1].Text != "two" {
+		t.Errorf("replayed %+v, want the event appended after the truncated line", events)
+	}
+}
+
+func TestTerminateLastLine(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		contents string
+		want     string
+	}{
+		{name: "empty", contents: "", want: ""},
+		{name: "terminated", contents: "{}\n", want: "{}\n"},
+		{name: "truncated", contents: "{}\n{", want: "{}\n{\n"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "events.jsonl")
+			if err := os.WriteFile(path, []byte(test.contents), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := terminateLastLine(path); err != nil {
+				t.Fatal(err)
+			}
+			contents, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(contents) != test.want {
+				t.Errorf("contents %q, want %q", contents, test.want)
+			}
+		})
+	}
+}
+
+func TestTerminateLastLineCreatesFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+
+	if err := terminateLastLine(path); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(path); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRecordKeepsEveryMessage(t *testing.T) {
+	eventLog := openTestLog(t, filepath.Join(t.TempDir(), "events.jsonl"))
+	broadcaster := chat.NewBroadcaster("rejected")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := eventLog.Record(ctx, KindRejected, broadcaster); err != nil {
+		t.Fatal(err)
+	}
+
+	// More than the buffer, faster than they can be written
+	const numMessages = 3 * recordBufferSize
+	for i := 0; i < numMessages; i++ {
+		broadcaster.NewMessage(chat.Message{Sender: "Jane", Recipient: "Everyone", Text: "?"})
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	var events []Event
+	for time.Now().Before(deadline) {
+		if events = replayAll(t, eventLog); len(events) == numMessages {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if len(events) != numMessages {
+		t.Fatalf("recorded %d events, want %d", len(events), numMessages)
+	}
+	if events[0].Kind != KindRejected {
+		t.Errorf("recorded %s event, want %s", events[0].Kind, KindRejected)
+	}
+}
@@ -0,0 +1,56 @@
+package eventlog
+
+import (
+	"presentation-service/internal/chat"
+	"presentation-service/internal/chat/counter"
+	"presentation-service/internal/chat/moderation"
+	"time"
+)
+
+type Kind string
+
+const (
+	KindChat          Kind = "chat"
+	KindRejected      Kind = "rejected"
+	KindTranscription Kind = "transcription"
+	KindReset         Kind = "reset"
+	KindModeration    Kind = "moderation"
+	KindPollState     Kind = "poll-state"
+	KindQuizNext      Kind = "quiz-next"
+)
+
+type Event struct {
+	Time      time.Time           `json:"ts"`
+	Kind      Kind                `json:"k"`
+	Message   *chat.Message       `json:"m,omitempty"`
+	Text      string              `json:"t,omitempty"`
+	Command   *moderation.Command `json:"c,omitempty"`
+	Poll      string              `json:"p,omitempty"`
+	PollState counter.State       `json:"ps,omitempty"`
+	Quiz      string              `json:"qz,omitempty"`
+}
+
+func ChatEvent(message chat.Message) Event {
+	return Event{Time: time.Now(), Kind: KindChat, Message: &message}
+}
+
+func TranscriptionEvent(text string) Event {
+	return Event{Time: time.Now(), Kind: KindTranscription, Text: text}
+}
+
+func ResetEvent() Event {
+	return Event{Time: time.Now(), Kind: KindReset}
+}
+
+func ModerationEvent(command moderation.Command) Event {
+	return Event{Time: time.Now(), Kind: KindModeration, Command: &command}
+}
+
+func PollStateEvent(poll string, state counter.State) Event {
+	return Event{Time: time.Now(), Kind: KindPollState, Poll: poll, PollState: state}
+}
+
+// The event time is when the question opened.
+func QuizNextEvent(quiz string) Event {
+	return Event{Time: time.Now(), Kind: KindQuizNext, Quiz: quiz}
+}
@@ -1,41 +1,272 @@
 package notification
 
 import (
+	"context"
+	"log"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
+type DeliveryPolicy struct {
+	bufferSize           int
+	timeout              time.Duration
+	disconnectOnOverflow bool
+	block                bool
+}
+
+// Buffers up to bufferSize values, discarding the oldest when full.
+func DropOldest(bufferSize int) DeliveryPolicy {
+	if bufferSize < 1 {
+		bufferSize = 1
+	}
+	return DeliveryPolicy{bufferSize: bufferSize}
+}
+
+// Buffers up to bufferSize values, then blocks NotifyAll until the subscriber
+// catches up - only for in-process subscribers that must not miss a value.
+func Block(bufferSize int) DeliveryPolicy {
+	if bufferSize < 1 {
+		bufferSize = 1
+	}
+	return DeliveryPolicy{bufferSize: bufferSize, block: true}
+}
+
+// Only ever holds the latest value - for snapshot types.
+func CoalesceLatest() DeliveryPolicy {
+	return DropOldest(1)
+}
+
+// Buffers up to bufferSize values, disconnecting the subscriber when the
+// buffer overflows, or when a value is not received within timeout (unless
+// timeout is 0).
+func DisconnectSlow(bufferSize int, timeout time.Duration) DeliveryPolicy {
+	if bufferSize < 1 {
+		bufferSize = 1
+	}
+	return DeliveryPolicy{bufferSize: bufferSize, timeout: timeout, disconnectOnOverflow: true}
+}
+
+// The values channel is owned by the Subscription, and is closed when the
+// subscription context is cancelled, Unsubscribe is called, or a
+// DisconnectSlow subscriber falls behind.
+type Subscription[T any] struct {
+	values       chan T
+	queue        chan T
+	policy       DeliveryPolicy
+	enqueueMutex sync.Mutex
+	ctx          context.Context
+	cancel       context.CancelFunc
+	ended        chan struct{}
+	dropped      uint64
+	disconnected uint32
+}
+
+func (s *Subscription[T]) Values() <-chan T {
+	return s.values
+}
+
+// Closed after the values channel is closed.
+func (s *Subscription[T]) Done() <-chan struct{} {
+	return s.ended
+}
+
+// Safe to call more than once.
+func (s *Subscription[T]) Unsubscribe() {
+	s.cancel()
+}
+
+func (s *Subscription[T]) Dropped() uint64 {
+	return atomic.LoadUint64(&s.dropped)
+}
+
+func (s *Subscription[T]) Disconnected() bool {
+	return atomic.LoadUint32(&s.disconnected) != 0
+}
+
+func (s *Subscription[T]) disconnect() {
+	atomic.StoreUint32(&s.disconnected, 1)
+	s.cancel()
+}
+
+// Only blocks for Block subscribers. Returns false if the subscriber should be
+// disconnected.
+func (s *Subscription[T]) enqueue(value T) bool {
+	s.enqueueMutex.Lock()
+	defer s.enqueueMutex.Unlock()
+	if s.policy.block {
+		select {
+		case s.queue <- value:
+		case <-s.ctx.Done():
+		}
+		return true
+	}
+	for {
+		select {
+		case s.queue <- value:
+			return true
+		default:
+		}
+		atomic.AddUint64(&s.dropped, 1)
+		if s.policy.disconnectOnOverflow {
+			return false
+		}
+		select {
+		case <-s.queue:
+		default:
+		}
+	}
+}
+
+func (s *Subscription[T]) forward() {
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case value := <-s.queue:
+			var timer *time.Timer
+			var timeout <-chan time.Time
+			if s.policy.timeout > 0 {
+				timer = time.NewTimer(s.policy.timeout)
+				timeout = timer.C
+			}
+			select {
+			case s.values <- value:
+				if timer != nil {
+					timer.Stop()
+				}
+			case <-timeout:
+				atomic.AddUint64(&s.dropped, 1)
+				s.disconnect()
+				return
+			case <-s.ctx.Done():
+				return
+			}
+		}
+	}
+}
+
 type Notification[T any] struct {
-	subscribers map[chan<- T]struct{}
+	subscribers map[*Subscription[T]]struct{}
 	mutex       sync.RWMutex
 }
 
 func (n *Notification[T]) NotifyAll(value T) {
+	n.mutex.RLock()
+	defer n.mutex.RUnlock()
 	for subscriber := range n.subscribers {
-		subscriber <- value
+		if !subscriber.enqueue(value) {
+			subscriber.disconnect()
+		}
 	}
-
 }
 
-func (n *Notification[T]) Subscribe(subscriber chan<- T) int {
-	n.mutex.Lock()
-	defer n.mutex.Unlock()
-	n.subscribers[subscriber] = struct{}{}
+func (n *Notification[T]) Count() int {
+	n.mutex.RLock()
+	defer n.mutex.RUnlock()
 
 	return len(n.subscribers)
 }
 
-func (n *Notification[T]) Unsubscribe(subscriber chan<- T) int {
-	close(subscriber)
+type SubscriberStats struct {
+	Buffered int    `json:"buffered"` // Values not yet received
+	Dropped  uint64 `json:"dropped"`
+}
+
+// For each current subscriber, to find who is lagging.
+func (n *Notification[T]) Stats() []SubscriberStats {
+	n.mutex.RLock()
+	defer n.mutex.RUnlock()
+	stats := make([]SubscriberStats, 0, len(n.subscribers))
+	for subscriber := range n.subscribers {
+		stats = append(stats, SubscriberStats{Buffered: len(subscriber.queue), Dropped: subscriber.Dropped()})
+	}
+
+	return stats
+}
+
+func (n *Notification[T]) Subscribe(
+	ctx context.Context, policy DeliveryPolicy,
+) (*Subscription[T], error) {
+	return n.subscribe(ctx, policy, nil)
+}
+
+// The initial value is delivered ahead of any value from NotifyAll.
+func (n *Notification[T]) SubscribeWithInitial(
+	ctx context.Context, policy DeliveryPolicy, initial T,
+) (*Subscription[T], error) {
+	return n.subscribe(ctx, policy, []T{initial})
+}
+
+// The backlog is delivered ahead of any value from NotifyAll, oldest first.
+// Only as many of the most recent values as the policy buffers are delivered.
+func (n *Notification[T]) SubscribeWithBacklog(
+	ctx context.Context, policy DeliveryPolicy, backlog []T,
+) (*Subscription[T], error) {
+	return n.subscribe(ctx, policy, backlog)
+}
+
+func (n *Notification[T]) subscribe(
+	ctx context.Context, policy DeliveryPolicy, backlog []T,
+) (*Subscription[T], error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if policy.bufferSize < 1 {
+		policy.bufferSize = 1
+	}
+	if len(backlog) > policy.bufferSize {
+		backlog = backlog[len(backlog)-policy.bufferSize:]
+	}
+	subCtx, cancel := context.WithCancel(ctx)
+	subscription := &Subscription[T]{
+		values: make(chan T),
+		queue:  make(chan T, policy.bufferSize),
+		policy: policy,
+		ctx:    subCtx,
+		cancel: cancel,
+		ended:  make(chan struct{}),
+	}
+	for _, value := range backlog {
+		subscription.enqueue(value)
+	}
 
 	n.mutex.Lock()
-	defer n.mutex.Unlock()
-	delete(n.subscribers, subscriber)
+	n.subscribers[subscription] = struct{}{}
+	n.mutex.Unlock()
 
-	return len(n.subscribers)
+	go func() {
+		subscription.forward()
+
+		n.mutex.Lock()
+		delete(n.subscribers, subscription)
+		n.mutex.Unlock()
+		close(subscription.values)
+		close(subscription.ended)
+	}()
+
+	return subscription, nil
+}
+
+// Notification or SequencedNotification, counting current subscribers.
+type counter interface {
+	Count() int
+}
+
+// Logs the subscriber joining n, and leaving once the subscription ends, as
+// e.g. "+1 chat message notification (=2)". Returns the subscription values.
+func LogSubscriber[T any](n counter, description string, subscription *Subscription[T]) <-chan T {
+	log.Printf("+1 %s (=%d)", description, n.Count())
+	go func() {
+		<-subscription.Done()
+		log.Printf("-1 %s (=%d, dropped %d)", description, n.Count(), subscription.Dropped())
+	}()
+
+	return subscription.Values()
 }
 
 func NewNotification[T any]() *Notification[T] {
 	return &Notification[T]{
-		subscribers: map[chan<- T]struct{}{},
+		subscribers: map[*Subscription[T]]struct{}{},
 	}
 }
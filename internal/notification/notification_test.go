@@ -0,0 +1,248 @@
+package notification
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+const testWait = time.Second
+
+// Receives values until none arrive for a short while.
+func receiveAvailable[T any](t *testing.T, values <-chan T) []T {
+	t.Helper()
+	var received []T
+	for {
+		select {
+		case value, ok := <-values:
+			if !ok {
+				return received
+			}
+			received = append(received, value)
+		case <-time.After(50 * time.Millisecond):
+			return received
+		}
+	}
+}
+
+func waitDone[T any](t *testing.T, subscription *Subscription[T]) {
+	t.Helper()
+	select {
+	case <-subscription.Done():
+	case <-time.After(testWait):
+		t.Fatal("subscription did not end")
+	}
+}
+
+func TestDropOldest(t *testing.T) {
+	n := NewNotification[int]()
+	subscription, err := n.Subscribe(context.Background(), DropOldest(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer subscription.Unsubscribe()
+
+	for i := 1; i <= 10; i++ {
+		n.NotifyAll(i)
+	}
+	received := receiveAvailable(t, subscription.Values())
+
+	// One value may already be on its way to the subscriber, besides the buffer
+	if len(received) < 2 || len(received) > 3 {
+		t.Fatalf("received %v, want the 2 or 3 latest values", received)
+	}
+	if received[len(received)-1] != 10 {
+		t.Errorf("received %v, want latest value 10 last", received)
+	}
+	for i := 1; i < len(received); i++ {
+		if received[i] <= received[i-1] {
+			t.Errorf("received %v out of order", received)
+		}
+	}
+	if dropped := subscription.Dropped(); dropped != uint64(10-len(received)) {
+		t.Errorf("dropped %d, want %d", dropped, 10-len(received))
+	}
+	if subscription.Disconnected() {
+		t.Error("disconnected, want still subscribed")
+	}
+}
+
+func TestCoalesceLatest(t *testing.T) {
+	n := NewNotification[int]()
+	subscription, err := n.SubscribeWithInitial(context.Background(), CoalesceLatest(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer subscription.Unsubscribe()
+
+	for i := 1; i <= 100; i++ {
+		n.NotifyAll(i)
+	}
+	received := receiveAvailable(t, subscription.Values())
+
+	if len(received) == 0 || len(received) > 2 || received[len(received)-1] != 100 {
+		t.Errorf("received %v, want at most 2 values ending with 100", received)
+	}
+}
+
+func TestDisconnectSlowOnTimeout(t *testing.T) {
+	n := NewNotification[int]()
+	subscription, err := n.Subscribe(context.Background(), DisconnectSlow(10, 20*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n.NotifyAll(1)
+	waitDone(t, subscription)
+
+	if !subscription.Disconnected() {
+		t.Error("not disconnected, want disconnected after timeout")
+	}
+	if _, ok := <-subscription.Values(); ok {
+		t.Error("values open, want closed")
+	}
+	if count := n.Count(); count != 0 {
+		t.Errorf("count %d, want 0", count)
+	}
+}
+
+func TestDisconnectSlowOnOverflowWithoutTimeout(t *testing.T) {
+	n := NewNotification[int]()
+	subscription, err := n.Subscribe(context.Background(), DisconnectSlow(1, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 1; i <= 3; i++ {
+		n.NotifyAll(i)
+	}
+	waitDone(t, subscription)
+
+	if !subscription.Disconnected() {
+		t.Error("not disconnected, want disconnected on overflow")
+	}
+	if subscription.Dropped() == 0 {
+		t.Error("dropped 0, want the overflowing value counted")
+	}
+}
+
+func TestUnsubscribeTwice(t *testing.T) {
+	n := NewNotification[int]()
+	subscription, err := n.Subscribe(context.Background(), DropOldest(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	subscription.Unsubscribe()
+	subscription.Unsubscribe()
+	waitDone(t, subscription)
+
+	if subscription.Disconnected() {
+		t.Error("disconnected, want unsubscribed")
+	}
+	if count := n.Count(); count != 0 {
+		t.Errorf("count %d, want 0", count)
+	}
+	n.NotifyAll(1)
+}
+
+func TestSubscriptionEndsWithContext(t *testing.T) {
+	n := NewNotification[int]()
+	ctx, cancel := context.WithCancel(context.Background())
+	subscription, err := n.Subscribe(ctx, DropOldest(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cancel()
+	waitDone(t, subscription)
+
+	if _, err = n.Subscribe(ctx, DropOldest(1)); err == nil {
+		t.Error("subscribed with cancelled context, want error")
+	}
+}
+
+func TestSubscribeWithBacklogKeepsLatest(t *testing.T) {
+	n := NewNotification[int]()
+	subscription, err := n.SubscribeWithBacklog(context.Background(), DropOldest(2), []int{1, 2, 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer subscription.Unsubscribe()
+
+	n.NotifyAll(4)
+	received := receiveAvailable(t, subscription.Values())
+
+	if received[len(received)-1] != 4 || received[0] < 2 {
+		t.Errorf("received %v, want the latest backlog then 4", received)
+	}
+}
+
+func TestStats(t *testing.T) {
+	n := NewNotification[int]()
+	lagging, err := n.Subscribe(context.Background(), DropOldest(4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lagging.Unsubscribe()
+
+	for i := 1; i <= 10; i++ {
+		n.NotifyAll(i)
+	}
+
+	stats := n.Stats()
+	if len(stats) != 1 {
+		t.Fatalf("stats %v, want 1 subscriber", stats)
+	}
+	if stats[0].Dropped < 5 || stats[0].Buffered > 4 {
+		t.Errorf("stats %+v, want at least 5 dropped and at most 4 buffered", stats[0])
+	}
+}
+
+func TestBlockDeliversEveryValue(t *testing.T) {
+	n := NewNotification[int]()
+	subscription, err := n.Subscribe(context.Background(), Block(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer subscription.Unsubscribe()
+
+	go func() {
+		for i := 1; i <= 100; i++ {
+			n.NotifyAll(i)
+		}
+	}()
+	for want := 1; want <= 100; want++ {
+		select {
+		case value := <-subscription.Values():
+			if value != want {
+				t.Fatalf("received %d, want %d", value, want)
+			}
+		case <-time.After(testWait):
+			t.Fatalf("did not receive %d", want)
+		}
+	}
+	if dropped := subscription.Dropped(); dropped != 0 {
+		t.Errorf("dropped %d, want 0", dropped)
+	}
+}
+
+func TestLogSubscriber(t *testing.T) {
+	n := NewNotification[int]()
+	ctx, cancel := context.WithCancel(context.Background())
+	subscription, err := n.Subscribe(ctx, DropOldest(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := LogSubscriber(n, "test subscriber", subscription)
+
+	n.NotifyAll(1)
+	if received := receiveAvailable(t, values); len(received) != 1 || received[0] != 1 {
+		t.Errorf("received %v, want [1]", received)
+	}
+	cancel()
+	waitDone(t, subscription)
+	if _, ok := <-values; ok {
+		t.Error("values still open after the subscription ended")
+	}
+}
@@ -0,0 +1,133 @@
+package notification
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"sync"
+)
+
+// For subscribers resuming after a value that can't be numbered, e.g. one
+// received before the server restarted.
+const UnknownSeq uint64 = math.MaxUint64
+
+// A value numbered in the order it was notified, from 1, so that
+// subscribers may resume after the last value they received.
+type Sequenced[T any] struct {
+	Seq   uint64
+	Value T
+	// Values up to Seq were missed, so the subscriber should refetch its
+	// state. Value is empty.
+	Reset bool
+}
+
+func (s Sequenced[T]) SequenceNumber() uint64 {
+	return s.Seq
+}
+
+func (s Sequenced[T]) IsReset() bool {
+	return s.Reset
+}
+
+// Encodes only the value - sequence numbers are sent out-of-band.
+func (s Sequenced[T]) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.Value)
+}
+
+// Numbers notified values, keeping the most recent for resuming subscribers.
+type SequencedNotification[T any] struct {
+	notification *Notification[Sequenced[T]]
+	recent       []Sequenced[T] // Oldest first
+	recentSize   int
+	lastSeq      uint64
+	mutex        sync.Mutex
+}
+
+func (n *SequencedNotification[T]) NotifyAll(value T) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	n.lastSeq++
+	sequenced := Sequenced[T]{Seq: n.lastSeq, Value: value}
+	if len(n.recent) == n.recentSize {
+		n.recent = n.recent[1:]
+	}
+	n.recent = append(n.recent, sequenced)
+	n.notification.NotifyAll(sequenced)
+}
+
+func (n *SequencedNotification[T]) Count() int {
+	return n.notification.Count()
+}
+
+func (n *SequencedNotification[T]) Stats() []SubscriberStats {
+	return n.notification.Stats()
+}
+
+// For streams of events. Recent values numbered after afterSeq are delivered
+// ahead of any value from NotifyAll, if afterSeq is not 0. If some of them are
+// no longer kept, or don't fit the policy buffer, or afterSeq is unknown, a
+// Reset is delivered first.
+func (n *SequencedNotification[T]) SubscribeAfter(
+	ctx context.Context, policy DeliveryPolicy, afterSeq uint64,
+) (*Subscription[Sequenced[T]], error) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	if afterSeq == 0 {
+		return n.notification.Subscribe(ctx, policy)
+	}
+	var backlog []Sequenced[T]
+	missed := afterSeq > n.lastSeq
+	if !missed {
+		for i, sequenced := range n.recent {
+			if sequenced.Seq > afterSeq {
+				backlog = n.recent[i:]
+				missed = sequenced.Seq > afterSeq+1
+				break
+			}
+		}
+	}
+	bufferSize := policy.bufferSize
+	if bufferSize < 1 {
+		bufferSize = 1
+	}
+	if len(backlog) > bufferSize || (missed && len(backlog) == bufferSize) {
+		// Leaving room for the reset
+		backlog = backlog[len(backlog)-bufferSize+1:]
+		missed = true
+	}
+	if missed {
+		reset := Sequenced[T]{Seq: n.lastSeq, Reset: true}
+		if len(backlog) > 0 {
+			reset.Seq = backlog[0].Seq - 1
+		}
+		backlog = append([]Sequenced[T]{reset}, backlog...)
+	}
+
+	return n.notification.SubscribeWithBacklog(ctx, policy, backlog)
+}
+
+// For streams of snapshots. The current snapshot is delivered ahead of any
+// value from NotifyAll, unless the subscriber has already received the most
+// recent one (afterSeq). current is called while no values can be notified.
+func (n *SequencedNotification[T]) SubscribeWithCurrent(
+	ctx context.Context, policy DeliveryPolicy, afterSeq uint64, current func() T,
+) (*Subscription[Sequenced[T]], error) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	if afterSeq > 0 && afterSeq == n.lastSeq {
+		return n.notification.Subscribe(ctx, policy)
+	}
+
+	return n.notification.SubscribeWithInitial(ctx, policy, Sequenced[T]{Seq: n.lastSeq, Value: current()})
+}
+
+// Keeps recentSize values for SubscribeAfter.
+func NewSequencedNotification[T any](recentSize int) *SequencedNotification[T] {
+	if recentSize < 1 {
+		recentSize = 1
+	}
+	return &SequencedNotification[T]{
+		notification: NewNotification[Sequenced[T]](),
+		recentSize:   recentSize,
+	}
+}
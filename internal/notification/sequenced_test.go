@@ -0,0 +1,106 @@
+package notification
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+type received struct {
+	seq   uint64
+	value int
+	reset bool
+}
+
+func subscribeAfter(t *testing.T, n *SequencedNotification[int], policy DeliveryPolicy, afterSeq uint64) []received {
+	t.Helper()
+	subscription, err := n.SubscribeAfter(context.Background(), policy, afterSeq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer subscription.Unsubscribe()
+	var values []received
+	for _, sequenced := range receiveAvailable(t, subscription.Values()) {
+		values = append(values, received{seq: sequenced.Seq, value: sequenced.Value, reset: sequenced.Reset})
+	}
+
+	return values
+}
+
+func TestSubscribeAfter(t *testing.T) {
+	n := NewSequencedNotification[int](3)
+	for i := 1; i <= 5; i++ {
+		n.NotifyAll(i * 10)
+	}
+
+	tests := []struct {
+		name     string
+		policy   DeliveryPolicy
+		afterSeq uint64
+		want     []received
+	}{
+		{"new subscriber", DropOldest(10), 0, nil},
+		{"latest", DropOldest(10), 5, nil},
+		{"resumable", DropOldest(10), 3, []received{{4, 40, false}, {5, 50, false}}},
+		{"oldest kept", DropOldest(10), 2, []received{{3, 30, false}, {4, 40, false}, {5, 50, false}}},
+		{"no longer kept", DropOldest(10), 1, []received{{2, 0, true}, {3, 30, false}, {4, 40, false}, {5, 50, false}}},
+		{"fits buffer", DropOldest(2), 3, []received{{4, 40, false}, {5, 50, false}}},
+		{"overflows buffer", DropOldest(2), 2, []received{{4, 0, true}, {5, 50, false}}},
+		{"no longer kept, fills buffer", DropOldest(3), 1, []received{{3, 0, true}, {4, 40, false}, {5, 50, false}}},
+		{"from the future", DropOldest(10), 6, []received{{5, 0, true}}},
+		{"unknown", DropOldest(10), UnknownSeq, []received{{5, 0, true}}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if values := subscribeAfter(t, n, test.policy, test.afterSeq); !reflect.DeepEqual(values, test.want) {
+				t.Errorf("received %v, want %v", values, test.want)
+			}
+		})
+	}
+}
+
+func TestSubscribeAfterThenNotified(t *testing.T) {
+	n := NewSequencedNotification[int](3)
+	n.NotifyAll(10)
+	subscription, err := n.SubscribeAfter(context.Background(), DropOldest(10), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer subscription.Unsubscribe()
+
+	n.NotifyAll(20)
+	values := receiveAvailable(t, subscription.Values())
+	if len(values) != 1 || values[0].Seq != 2 || values[0].Value != 20 || values[0].Reset {
+		t.Errorf("received %v, want only #2", values)
+	}
+}
+
+func TestSubscribeWithCurrent(t *testing.T) {
+	n := NewSequencedNotification[int](1)
+	n.NotifyAll(10)
+	n.NotifyAll(20)
+	current := func() int { return 20 }
+
+	tests := []struct {
+		name     string
+		afterSeq uint64
+		want     []Sequenced[int]
+	}{
+		{"new subscriber", 0, []Sequenced[int]{{Seq: 2, Value: 20}}},
+		{"latest", 2, nil},
+		{"behind", 1, []Sequenced[int]{{Seq: 2, Value: 20}}},
+		{"unknown", UnknownSeq, []Sequenced[int]{{Seq: 2, Value: 20}}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			subscription, err := n.SubscribeWithCurrent(context.Background(), CoalesceLatest(), test.afterSeq, current)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer subscription.Unsubscribe()
+			if values := receiveAvailable(t, subscription.Values()); !reflect.DeepEqual(values, test.want) {
+				t.Errorf("received %v, want %v", values, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,31 @@
+package token
+
+import (
+	"regexp"
+	"strings"
+)
+
+const (
+	ChoiceExtractorName = "choice"
+	DefaultChoices      = 4
+)
+
+// The whole message must be the answer, e.g. "A", "b)", "(c)" or "option D",
+// so that articles and pronouns in conversation aren't counted.
+var choiceRegex = regexp.MustCompile(`(?i)^\s*(?:(?:option|answer|choice)[:\s]\s*)?\(?([a-z])\s*[).:!]?\s*$`)
+
+// Extracts one of the first numChoices letters as an uppercase token.
+func NewChoiceExtractor(numChoices int) func(string) []string {
+	return func(text string) []string {
+		match := choiceRegex.FindStringSubmatch(text)
+		if match == nil {
+			return nil
+		}
+		choice := strings.ToUpper(match[1])
+		if int(choice[0]-'A') >= numChoices {
+			return nil
+		}
+
+		return []string{choice}
+	}
+}
@@ -0,0 +1,44 @@
+package token
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestChoiceExtractor(t *testing.T) {
+	extract := NewChoiceExtractor(DefaultChoices)
+
+	for _, test := range []struct {
+		text string
+		want []string
+	}{
+		{text: "A", want: []string{"A"}},
+		{text: "b", want: []string{"B"}},
+		{text: "  c)  ", want: []string{"C"}},
+		{text: "(d)", want: []string{"D"}},
+		{text: "b.", want: []string{"B"}},
+		{text: "option c", want: []string{"C"}},
+		{text: "Answer: a", want: []string{"A"}},
+		{text: "choice d!", want: []string{"D"}},
+		{text: "e", want: nil},
+		{text: "a or b", want: nil},
+		{text: "I think a", want: nil},
+		{text: "a good question", want: nil},
+		{text: "ab", want: nil},
+		{text: "", want: nil},
+	} {
+		if got := extract(test.text); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("extracted %v from %q, want %v", got, test.text, test.want)
+		}
+	}
+}
+
+func TestChoiceExtractorNumChoices(t *testing.T) {
+	extract := NewChoiceExtractor(2)
+	if got := extract("b"); !reflect.DeepEqual(got, []string{"B"}) {
+		t.Errorf("extracted %v, want [B]", got)
+	}
+	if got := extract("c"); got != nil {
+		t.Errorf("extracted %v, want choices beyond the second ignored", got)
+	}
+}
@@ -0,0 +1,181 @@
+package token
+
+import (
+	_ "embed"
+	"errors"
+	"strings"
+)
+
+const (
+	defaultFuzzyMinWordLength = 5
+	// Words at least this long may be a further edit away, up to MaxDistance
+	fuzzyLengthPerEdit = 8
+)
+
+//go:embed dictionary/english.txt
+var englishDictionary string
+
+// Common English words, which are more likely meant as written than as a
+// misspelled alias, so are never fuzzy matched.
+var dictionaryWords = wordSet(strings.Fields(englishDictionary)...)
+
+func isCommonWord(word string) bool {
+	_, stopWord := stopWordsByLanguage["english"][word]
+	_, dictionaryWord := dictionaryWords[word]
+
+	return stopWord || dictionaryWord
+}
+
+type FuzzyMatch struct {
+	Text     string `json:"text"`
+	Word     string `json:"word"`
+	Token    string `json:"token"`
+	Distance int    `json:"distance"`
+}
+
+type FuzzyOptions struct {
+	// Maximum number of single character edits (including transpositions),
+	// allowed only for long words - words shorter than 8 are allowed 1 edit,
+	// shorter than 16, 2 edits, and so on
+	MaxDistance int `json:"maxDistance"`
+	// Shorter words and aliases are only matched exactly (default 5)
+	MinWordLength int `json:"minWordLength,omitempty"`
+}
+
+func (o FuzzyOptions) minWordLength() int {
+	if o.MinWordLength == 0 {
+		return defaultFuzzyMinWordLength
+	}
+
+	return o.MinWordLength
+}
+
+// The allowed distance between words of length runes.
+func (o FuzzyOptions) maxDistanceFor(length int) int {
+	if distance := 1 + length/fuzzyLengthPerEdit; distance < o.MaxDistance {
+		return distance
+	}
+
+	return o.MaxDistance
+}
+
+func (o FuzzyOptions) Validate() error {
+	if o.MaxDistance < 1 {
+		return errors.New("maxDistance must be at least 1")
+	}
+	if o.MinWordLength < 0 || o.minWordLength() <= o.MaxDistance {
+		return errors.New("minWordLength must be greater than maxDistance")
+	}
+
+	return nil
+}
+
+// Optimal string alignment distance, or max+1 if it exceeds max.
+func editDistance(a, b []rune, max int) int {
+	if len(a)-len(b) > max || len(b)-len(a) > max {
+		return max + 1
+	}
+
+	twoRowsAgo := make([]int, len(b)+1)
+	prevRow := make([]int, len(b)+1)
+	row := make([]int, len(b)+1)
+	for j := range prevRow {
+		prevRow[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		row[0] = i
+		rowMin := row[0]
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			row[j] = prevRow[j-1] + cost
+			if prevRow[j]+1 < row[j] {
+				row[j] = prevRow[j] + 1
+			}
+			if row[j-1]+1 < row[j] {
+				row[j] = row[j-1] + 1
+			}
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] && twoRowsAgo[j-2]+1 < row[j] {
+				row[j] = twoRowsAgo[j-2] + 1
+			}
+			if row[j] < rowMin {
+				rowMin = row[j]
+			}
+		}
+		if rowMin > max {
+			return max + 1
+		}
+		twoRowsAgo, prevRow, row = prevRow, row, twoRowsAgo
+	}
+	if prevRow[len(b)] > max {
+		return max + 1
+	}
+
+	return prevRow[len(b)]
+}
+
+// Finds the token for the closest alias to word, rejecting matches where
+// aliases for different tokens are equally close. The allowed distance scales
+// with the shorter of the word and alias. Only single-word aliases are
+// considered, and word must be lowercase. Common words are never matched.
+func (o FuzzyOptions) match(vocab vocabulary, word string) (string, int, bool) {
+	wordRunes := []rune(word)
+	minWordLength := o.minWordLength()
+	if len(wordRunes) < minWordLength || o.MaxDistance < 1 || isCommonWord(word) {
+		return "", 0, false
+	}
+
+	bestToken := ""
+	bestDistance := o.MaxDistance + 1
+	ambiguous := false
+	for alias, token := range vocab.tokensByAlias {
+		aliasRunes := []rune(alias)
+		if len(aliasRunes) < minWordLength || strings.Contains(alias, " ") {
+			continue
+		}
+		shorterLength := len(wordRunes)
+		if len(aliasRunes) < shorterLength {
+			shorterLength = len(aliasRunes)
+		}
+		maxDistance := o.maxDistanceFor(shorterLength)
+		distance := editDistance(wordRunes, aliasRunes, maxDistance)
+		if distance > maxDistance {
+			continue
+		}
+		switch {
+		case distance < bestDistance:
+			bestToken = token
+			bestDistance = distance
+			ambiguous = false
+		case distance == bestDistance && token != bestToken:
+			ambiguous = true
+		}
+	}
+	if bestDistance > o.MaxDistance || ambiguous {
+		return "", 0, false
+	}
+
+	return bestToken, bestDistance, true
+}
+
+type fuzzyExtraction struct {
+	options FuzzyOptions
+	onMatch func(FuzzyMatch)
+}
+
+func (f *fuzzyExtraction) match(vocab vocabulary, text, word string) string {
+	if f == nil {
+		return ""
+	}
+	token, distance, ok := f.options.match(vocab, strings.ToLower(word))
+	if !ok {
+		return ""
+	}
+	if f.onMatch != nil {
+		f.onMatch(FuzzyMatch{Text: text, Word: word, Token: token, Distance: distance})
+	}
+
+	return token
+}
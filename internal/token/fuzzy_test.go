@@ -0,0 +1,118 @@
+package token
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFuzzyLanguages(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		options FuzzyOptions
+		text    string
+		want    []string
+	}{
+		{
+			name: "transposition", options: FuzzyOptions{MaxDistance: 1},
+			text: "I think it is pyhton so", want: []string{"Python"},
+		},
+		{
+			name: "short words only match exactly", options: FuzzyOptions{MaxDistance: 2, MinWordLength: 4},
+			text: "I love cats and java pyhton", want: []string{"Java", "Python"},
+		},
+		{
+			name: "distance scales with length", options: FuzzyOptions{MaxDistance: 2},
+			text: "pythn or pyhtn", want: []string{"Python"},
+		},
+		{
+			name: "long words allow more edits", options: FuzzyOptions{MaxDistance: 2},
+			text: "javascirpt and typscrpt", want: []string{"JavaScript", "TypeScript"},
+		},
+		{
+			name: "exact matches are unaffected", options: FuzzyOptions{MaxDistance: 1},
+			text: "Go and C", want: []string{"Go", "C"},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var matches []FuzzyMatch
+			extract := NewFuzzyVocabularyExtractor(builtInVocabularies["languages"].tokensByAlias, test.options, func(match FuzzyMatch) {
+				matches = append(matches, match)
+			})
+
+			got := extract(test.text)
+
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("extracted %v from %q, want %v (fuzzy matches %+v)", got, test.text, test.want, matches)
+			}
+		})
+	}
+}
+
+func TestFuzzyLanguagesIgnoresCommonWords(t *testing.T) {
+	for _, options := range []FuzzyOptions{{MaxDistance: 1}, {MaxDistance: 2}} {
+		var matches []FuzzyMatch
+		extract := NewFuzzyVocabularyExtractor(builtInVocabularies["languages"].tokensByAlias, options, func(match FuzzyMatch) {
+			matches = append(matches, match)
+		})
+
+		for _, text := range []string{
+			"I just love lists", "shift it", "scale it", "peril", "what a closure", "looking sharp", "ensure it works",
+		} {
+			if got := extract(text); len(got) != 0 {
+				t.Errorf("%+v extracted %v from %q, want nothing (fuzzy matches %+v)", options, got, text, matches)
+			}
+		}
+	}
+}
+
+func TestFuzzyMatchRejectsAmbiguousAliases(t *testing.T) {
+	vocab := newVocabularyUnchecked(map[string]string{"vimm": "Vim", "vime": "Vime"})
+	options := FuzzyOptions{MaxDistance: 1, MinWordLength: 4}
+
+	if token, _, ok := options.match(vocab, "vimx"); ok {
+		t.Errorf(`matched "vimx" to %s, want no match between equally close aliases`, token)
+	}
+	if token, distance, ok := options.match(vocab, "vimmm"); !ok || token != "Vim" || distance != 1 {
+		t.Errorf(`matched "vimmm" to %s (distance %d, %t), want Vim`, token, distance, ok)
+	}
+}
+
+func TestFuzzyMatchRequiresMinWordLength(t *testing.T) {
+	vocab := newVocabularyUnchecked(map[string]string{"rust": "Rust", "elm": "Elm"})
+
+	for _, test := range []struct {
+		options FuzzyOptions
+		word    string
+		want    bool
+	}{
+		{options: FuzzyOptions{MaxDistance: 1}, word: "rist", want: false},
+		{options: FuzzyOptions{MaxDistance: 1, MinWordLength: 4}, word: "rist", want: true},
+		{options: FuzzyOptions{MaxDistance: 1}, word: "elk", want: false},
+		{options: FuzzyOptions{MaxDistance: 1}, word: "rst", want: false},
+		{options: FuzzyOptions{MaxDistance: 1, MinWordLength: 5}, word: "rusts", want: false},
+		{options: FuzzyOptions{MaxDistance: 1, MinWordLength: 3}, word: "elk", want: true},
+	} {
+		if _, _, ok := test.options.match(vocab, test.word); ok != test.want {
+			t.Errorf("%+v matched %q: %t, want %t", test.options, test.word, ok, test.want)
+		}
+	}
+}
+
+func TestFuzzyOptionsValidate(t *testing.T) {
+	for _, test := range []struct {
+		options FuzzyOptions
+		valid   bool
+	}{
+		{options: FuzzyOptions{MaxDistance: 1}, valid: true},
+		{options: FuzzyOptions{MaxDistance: 2, MinWordLength: 4}, valid: true},
+		{options: FuzzyOptions{MaxDistance: 0}, valid: false},
+		{options: FuzzyOptions{MaxDistance: 2, MinWordLength: 2}, valid: false},
+		{options: FuzzyOptions{MaxDistance: 4}, valid: true},
+		{options: FuzzyOptions{MaxDistance: 5}, valid: false},
+		{options: FuzzyOptions{MaxDistance: 1, MinWordLength: -1}, valid: false},
+	} {
+		if err := test.options.Validate(); (err == nil) != test.valid {
+			t.Errorf("%+v validated (%v), want valid %t", test.options, err, test.valid)
+		}
+	}
+}
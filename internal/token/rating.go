@@ -0,0 +1,48 @@
+package token
+
+import (
+	"regexp"
+	"strconv"
+)
+
+const RatingExtractorName = "rating"
+
+type RatingScale struct {
+	Min int `json:"min"`
+	Max int `json:"max"`
+}
+
+var DefaultRatingScale = RatingScale{Min: 1, Max: 5}
+
+// The whole message must be the rating, e.g. "4", "7/10" or "7 out of 10".
+var ratingRegex = regexp.MustCompile(`(?i)^\s*(\d{1,3})\s*(?:(?:/|out\s+of)\s*(\d{1,3}))?\s*[.!]*\s*$`)
+
+func (s RatingScale) Valid() bool {
+	return s.Min >= 0 && s.Max > s.Min && s.Max-s.Min <= 100
+}
+
+func (s RatingScale) Contains(rating int) bool {
+	return rating >= s.Min && rating <= s.Max
+}
+
+// Extracts ratings within scale as decimal tokens. Ratings out of some other
+// maximum (e.g. "7/10" on a 1-5 scale) are ignored, rather than rescaled.
+func NewRatingExtractor(scale RatingScale) func(string) []string {
+	return func(text string) []string {
+		match := ratingRegex.FindStringSubmatch(text)
+		if match == nil {
+			return nil
+		}
+		rating, _ := strconv.Atoi(match[1])
+		if !scale.Contains(rating) {
+			return nil
+		}
+		if match[2] != "" {
+			if outOf, _ := strconv.Atoi(match[2]); outOf != scale.Max {
+				return nil
+			}
+		}
+
+		return []string{strconv.Itoa(rating)}
+	}
+}
@@ -0,0 +1,53 @@
+package token
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRatingExtractor(t *testing.T) {
+	extract := NewRatingExtractor(DefaultRatingScale)
+
+	for _, test := range []struct {
+		text string
+		want []string
+	}{
+		{text: "4", want: []string{"4"}},
+		{text: " 5! ", want: []string{"5"}},
+		{text: "1.", want: []string{"1"}},
+		{text: "3/5", want: []string{"3"}},
+		{text: "3 / 5", want: []string{"3"}},
+		{text: "2 out of 5", want: []string{"2"}},
+		{text: "04", want: []string{"4"}},
+		{text: "0", want: nil},
+		{text: "6", want: nil},
+		{text: "7/10", want: nil},
+		{text: "4 out of 10", want: nil},
+		{text: "4.5", want: nil},
+		{text: "I'd say 4", want: nil},
+		{text: "4 stars", want: nil},
+		{text: "", want: nil},
+	} {
+		if got := extract(test.text); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("extracted %v from %q, want %v", got, test.text, test.want)
+		}
+	}
+}
+
+func TestRatingScaleValid(t *testing.T) {
+	for _, test := range []struct {
+		scale RatingScale
+		want  bool
+	}{
+		{scale: DefaultRatingScale, want: true},
+		{scale: RatingScale{Min: 0, Max: 10}, want: true},
+		{scale: RatingScale{Min: 0, Max: 100}, want: true},
+		{scale: RatingScale{Min: 0, Max: 101}, want: false},
+		{scale: RatingScale{Min: 5, Max: 5}, want: false},
+		{scale: RatingScale{Min: -1, Max: 5}, want: false},
+	} {
+		if valid := test.scale.Valid(); valid != test.want {
+			t.Errorf("%+v valid: %t, want %t", test.scale, valid, test.want)
+		}
+	}
+}
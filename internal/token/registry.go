@@ -0,0 +1,145 @@
+package token
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"strings"
+	"sync"
+)
+
+const vocabularyFileExt = ".json"
+
+// Vocabularies loaded from a directory of JSON files, each mapping aliases to
+// their tokens as poll vocabularies do, and named after the file (e.g.
+// editors.json -> "editors"). Loaded vocabularies take precedence over
+// built-in vocabularies of the same name.
+type VocabularyRegistry struct {
+	dir                string
+	vocabulariesByName map[string]vocabulary
+	mutex              sync.RWMutex
+}
+
+// Decodes a JSON object of aliases to tokens, rejecting repeated aliases,
+// which json.Unmarshal would silently overwrite.
+func decodeTokensByAlias(data []byte) (map[string]string, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	if delim, err := decoder.Token(); err != nil || delim != json.Delim('{') {
+		return nil, fmt.Errorf("expected an object of aliases to tokens")
+	}
+	tokensByAlias := map[string]string{}
+	for decoder.More() {
+		key, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		alias := key.(string)
+		if _, repeated := tokensByAlias[alias]; repeated {
+			return nil, fmt.Errorf(`repeated alias "%s"`, alias)
+		}
+		var token string
+		if err = decoder.Decode(&token); err != nil {
+			return nil, fmt.Errorf(`alias "%s" (%w)`, alias, err)
+		}
+		tokensByAlias[alias] = token
+	}
+	if _, err := decoder.Token(); err != nil {
+		return nil, err
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return nil, fmt.Errorf("unexpected data after the object of aliases")
+	}
+
+	return tokensByAlias, nil
+}
+
+// Reads every vocabulary file in fsys.
+func readVocabularies(fsys fs.FS) (map[string]vocabulary, error) {
+	paths, err := fs.Glob(fsys, "*"+vocabularyFileExt)
+	if err != nil {
+		return nil, err
+	}
+
+	vocabulariesByName := make(map[string]vocabulary, len(paths))
+	for _, path := range paths {
+		data, readErr := fs.ReadFile(fsys, path)
+		if readErr != nil {
+			return nil, readErr
+		}
+		tokensByAlias, readErr := decodeTokensByAlias(data)
+		if readErr != nil {
+			return nil, fmt.Errorf("invalid vocabulary %s (%w)", path, readErr)
+		}
+		vocab, readErr := newVocabulary(tokensByAlias)
+		if readErr != nil {
+			return nil, fmt.Errorf("invalid vocabulary %s (%w)", path, readErr)
+		}
+		vocabulariesByName[strings.TrimSuffix(path, vocabularyFileExt)] = vocab
+	}
+
+	return vocabulariesByName, nil
+}
+
+// All-or-nothing - on error, previously loaded vocabularies are retained.
+// Removing a loaded vocabulary's file is an error, since extractors already
+// built from it would otherwise match nothing.
+func (r *VocabularyRegistry) Reload() error {
+	vocabulariesByName, err := readVocabularies(os.DirFS(r.dir))
+	if err != nil {
+		return err
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for name := range r.vocabulariesByName {
+		if _, ok := vocabulariesByName[name]; !ok {
+			return fmt.Errorf("vocabulary %s%s was removed from %s", name, vocabularyFileExt, r.dir)
+		}
+	}
+	r.vocabulariesByName = vocabulariesByName
+	log.Printf("Loaded %d vocabularies from %s", len(vocabulariesByName), r.dir)
+
+	return nil
+}
+
+// Looks up loaded vocabularies, then built-in extractors. Extractors for
+// loaded vocabularies always use the most recently loaded aliases, and are
+// matched fuzzily if fuzzy is not nil.
+// A nil registry only resolves built-in extractors.
+func (r *VocabularyRegistry) Extractor(
+	name string, fuzzy *FuzzyOptions, onFuzzyMatch func(FuzzyMatch),
+) (func(string) []string, bool) {
+	if r != nil {
+		r.mutex.RLock()
+		_, loaded := r.vocabulariesByName[name]
+		r.mutex.RUnlock()
+		if loaded {
+			var fuzzyMatching *fuzzyExtraction
+			if fuzzy != nil {
+				fuzzyMatching = &fuzzyExtraction{options: *fuzzy, onMatch: onFuzzyMatch}
+			}
+			return func(text string) []string {
+				r.mutex.RLock()
+				vocab := r.vocabulariesByName[name]
+				r.mutex.RUnlock()
+
+				return vocab.extract(fuzzyMatching, text)
+			}, true
+		}
+	}
+
+	return ExtractorByName(name, fuzzy, onFuzzyMatch)
+}
+
+func NewVocabularyRegistry(dir string) (*VocabularyRegistry, error) {
+	registry := &VocabularyRegistry{dir: dir}
+	if err := registry.Reload(); err != nil {
+		return nil, err
+	}
+
+	return registry, nil
+}
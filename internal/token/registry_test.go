@@ -0,0 +1,137 @@
+package token
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func writeVocabularyFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBuiltInVocabularies(t *testing.T) {
+	for name, test := range map[string]struct {
+		text string
+		want []string
+	}{
+		"languages":    {text: "golang, then Python", want: []string{"Go", "Python"}},
+		"yes-no-maybe": {text: "not sure", want: []string{"Maybe"}},
+	} {
+		extract, ok := ExtractorByName(name, nil, nil)
+		if !ok {
+			t.Fatalf("no built-in %s extractor", name)
+		}
+		if got := extract(test.text); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s extracted %v from %q, want %v", name, got, test.text, test.want)
+		}
+	}
+}
+
+func TestVocabularyRegistryLoadsFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeVocabularyFile(t, dir, "editors.json", `{"vim": "Vim", "Visual Studio Code": "VS Code"}`)
+	writeVocabularyFile(t, dir, "languages.json", `{"go": "Golang"}`)
+	writeVocabularyFile(t, dir, "notes.txt", `not a vocabulary`)
+
+	registry, err := NewVocabularyRegistry(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	extract, ok := registry.Extractor("editors", nil, nil)
+	if !ok {
+		t.Fatal("no editors extractor")
+	}
+	if got := extract("vim or visual studio code"); !reflect.DeepEqual(got, []string{"Vim", "VS Code"}) {
+		t.Errorf("extracted %v, want [Vim VS Code]", got)
+	}
+	extract, _ = registry.Extractor("languages", nil, nil)
+	if got := extract("go"); !reflect.DeepEqual(got, []string{"Golang"}) {
+		t.Errorf("extracted %v, want the loaded languages vocabulary to replace the built-in", got)
+	}
+	if _, ok = registry.Extractor("notes", nil, nil); ok {
+		t.Error("loaded notes.txt, want only JSON files loaded")
+	}
+	if _, ok = registry.Extractor("yes-no-maybe", nil, nil); !ok {
+		t.Error("no yes-no-maybe extractor, want built-in extractors resolved")
+	}
+}
+
+func TestVocabularyRegistryRejectsInvalidFiles(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{name: "malformed", content: `{"vim": ["Vim"]}`, wantErr: "cannot unmarshal"},
+		{name: "duplicate alias", content: `{"VS Code": "VS Code", "vs  code": "Code"}`, wantErr: "duplicate alias"},
+		{name: "repeated alias", content: `{"go": "Go", "go": "Golang"}`, wantErr: `repeated alias "go"`},
+		{name: "not an object", content: `[{"vim": "Vim"}]`, wantErr: "expected an object"},
+		{name: "trailing data", content: `{"vim": "Vim"} {"emacs": "Emacs"}`, wantErr: "unexpected data"},
+		{name: "unextractable alias", content: `{"c/c++": "C"}`, wantErr: "can never be extracted"},
+		{name: "empty token", content: `{"vim": ""}`, wantErr: "has no token"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeVocabularyFile(t, dir, "editors.json", test.content)
+
+			_, err := NewVocabularyRegistry(dir)
+
+			if err == nil || !strings.Contains(err.Error(), "editors.json") || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("error %v, want %q in editors.json", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestVocabularyRegistryReload(t *testing.T) {
+	dir := t.TempDir()
+	writeVocabularyFile(t, dir, "editors.json", `{"vim": "Vim"}`)
+	registry, err := NewVocabularyRegistry(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	extract, _ := registry.Extractor("editors", nil, nil)
+
+	writeVocabularyFile(t, dir, "editors.json", `{"vim": "Vim", "emacs": "Emacs"}`)
+	if err = registry.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if got := extract("emacs"); !reflect.DeepEqual(got, []string{"Emacs"}) {
+		t.Errorf("extracted %v, want existing extractors to use reloaded aliases", got)
+	}
+
+	writeVocabularyFile(t, dir, "editors.json", `{"vim": "Vim", "VIM": "Neovim"}`)
+	if err = registry.Reload(); err == nil {
+		t.Error("reloaded a duplicate alias")
+	}
+	if got := extract("emacs vim"); !reflect.DeepEqual(got, []string{"Emacs", "Vim"}) {
+		t.Errorf("extracted %v, want previous aliases retained after a failed reload", got)
+	}
+}
+
+func TestVocabularyRegistryReloadKeepsRemovedVocabularies(t *testing.T) {
+	dir := t.TempDir()
+	writeVocabularyFile(t, dir, "editors.json", `{"vim": "Vim"}`)
+	registry, err := NewVocabularyRegistry(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	extract, _ := registry.Extractor("editors", nil, nil)
+
+	if err = os.Remove(filepath.Join(dir, "editors.json")); err != nil {
+		t.Fatal(err)
+	}
+	if err = registry.Reload(); err == nil || !strings.Contains(err.Error(), "editors.json was removed") {
+		t.Errorf("error %v, want editors.json removed", err)
+	}
+	if got := extract("vim"); !reflect.DeepEqual(got, []string{"Vim"}) {
+		t.Errorf("extracted %v, want the previous vocabulary retained", got)
+	}
+}
@@ -0,0 +1,48 @@
+package token
+
+func wordSet(words ...string) map[string]struct{} {
+	set := make(map[string]struct{}, len(words))
+	for _, word := range words {
+		set[word] = struct{}{}
+	}
+
+	return set
+}
+
+var stopWordsByLanguage = map[string]map[string]struct{}{
+	"english": wordSet(
+		"a", "about", "above", "after", "again", "all", "also", "am", "an", "and", "any", "are",
+		"as", "at", "be", "because", "been", "before", "being", "below", "between", "both",
+		"but", "by", "can", "could", "did", "do", "does", "doing", "don't", "down", "during",
+		"each", "few", "for", "from", "further", "had", "has", "have", "having", "he", "her",
+		"here", "hers", "him", "his", "how", "i", "i'm", "if", "in", "into", "is", "it", "it's",
+		"its", "just", "like", "me", "more", "most", "my", "no", "nor", "not", "now", "of",
+		"off", "on", "once", "only", "or", "other", "our", "ours", "out", "over", "own",
+		"really", "same", "she", "should", "so", "some", "such", "than", "that", "that's",
+		"the", "their", "theirs", "them", "then", "there", "these", "they", "this", "those",
+		"through", "to", "too", "under", "until", "up", "us", "very", "was", "we", "were",
+		"what", "when", "where", "which", "while", "who", "whom", "why", "will", "with",
+		"would", "yes", "you", "your", "yours",
+	),
+	"spanish": wordSet(
+		"a", "al", "algo", "como", "con", "de", "del", "donde", "el", "ella", "ellos", "en",
+		"es", "esa", "ese", "esta", "este", "esto", "fue", "ha", "hay", "la", "las", "le",
+		"les", "lo", "los", "mas", "más", "me", "mi", "muy", "no", "nos", "o", "para", "pero",
+		"por", "que", "qué", "se", "si", "sí", "sin", "son", "su", "sus", "también", "te",
+		"tu", "un", "una", "uno", "y", "ya", "yo",
+	),
+	"french": wordSet(
+		"a", "à", "au", "aux", "avec", "ce", "ces", "c'est", "dans", "de", "des", "du", "elle",
+		"en", "est", "et", "il", "ils", "je", "j'ai", "la", "le", "les", "leur", "lui", "ma",
+		"mais", "me", "mes", "moi", "mon", "ne", "nous", "on", "ou", "où", "par", "pas",
+		"pour", "qu'il", "que", "qui", "sa", "se", "ses", "son", "sur", "ta", "te", "tes",
+		"toi", "ton", "tu", "un", "une", "vos", "votre", "vous", "y",
+	),
+	"german": wordSet(
+		"aber", "als", "am", "an", "auch", "auf", "aus", "bei", "bin", "bis", "das", "dass",
+		"dem", "den", "der", "des", "die", "du", "ein", "eine", "einen", "er", "es", "für",
+		"hat", "ich", "ihr", "im", "in", "ist", "ja", "mit", "nach", "nicht", "noch", "nur",
+		"oder", "sie", "sind", "so", "und", "uns", "von", "vor", "war", "was", "wie", "wir",
+		"zu", "zum", "zur",
+	),
+}
@@ -0,0 +1,212 @@
+package token
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"strings"
+)
+
+var wordSeparatorRegex = regexp.MustCompile("[\\s!\"&,./?|]+")
+
+// Lowercase, with the words of multi-word aliases separated by a single space.
+func normalizeAlias(alias string) string {
+	return strings.Join(strings.Fields(strings.ToLower(alias)), " ")
+}
+
+type vocabulary struct {
+	tokensByAlias map[string]string // Normalized aliases
+	maxAliasWords int
+}
+
+func newVocabulary(tokensByAlias map[string]string) (vocabulary, error) {
+	vocab := vocabulary{
+		tokensByAlias: make(map[string]string, len(tokensByAlias)),
+		maxAliasWords: 1,
+	}
+	for alias, token := range tokensByAlias {
+		normalizedAlias := normalizeAlias(alias)
+		words := strings.Split(normalizedAlias, " ")
+		for _, word := range words {
+			if word == "" || wordSeparatorRegex.MatchString(word) {
+				return vocabulary{}, fmt.Errorf(`alias "%s" can never be extracted`, alias)
+			}
+		}
+		if token == "" {
+			return vocabulary{}, fmt.Errorf(`alias "%s" has no token`, alias)
+		}
+		if existing, duplicate := vocab.tokensByAlias[normalizedAlias]; duplicate {
+			return vocabulary{}, fmt.Errorf(
+				`duplicate alias "%s" (for "%s" and "%s")`, alias, existing, token,
+			)
+		}
+		vocab.tokensByAlias[normalizedAlias] = token
+		if len(words) > vocab.maxAliasWords {
+			vocab.maxAliasWords = len(words)
+		}
+	}
+
+	return vocab, nil
+}
+
+// Rejects duplicate aliases, and aliases that can never be produced by
+// splitting text into words. Aliases may be multi-word phrases.
+func ValidateVocabulary(tokensByAlias map[string]string) error {
+	_, err := newVocabulary(tokensByAlias)
+
+	return err
+}
+
+// Returns the token for the longest alias at the start of words, and the
+// number of words it spans (at least 1, even if there is no match).
+func (v vocabulary) longestMatch(words []string) (string, int) {
+	numWords := v.maxAliasWords
+	if numWords > len(words) {
+		numWords = len(words)
+	}
+	for ; numWords > 1; numWords-- {
+		if token, ok := v.tokensByAlias[strings.Join(words[:numWords], " ")]; ok {
+			return token, numWords
+		}
+	}
+
+	return v.tokensByAlias[words[0]], 1
+}
+
+// Splits text into words, and for each word, the index after the last word in
+// its run - words separated only by whitespace, within which phrases match.
+func splitWords(text string) ([]string, []int) {
+	separators := wordSeparatorRegex.FindAllStringIndex(text, -1)
+	words := make([]string, 0, len(separators)+1)
+	breaksPhrase := make([]bool, 0, len(separators))
+	start := 0
+	for _, separator := range separators {
+		words = append(words, text[start:separator[0]])
+		breaksPhrase = append(breaksPhrase, strings.TrimSpace(text[separator[0]:separator[1]]) != "")
+		start = separator[1]
+	}
+	words = append(words, text[start:])
+
+	runEnds := make([]int, len(words))
+	runEnds[len(words)-1] = len(words)
+	for i := len(words) - 2; i >= 0; i-- {
+		runEnds[i] = runEnds[i+1]
+		if breaksPhrase[i] {
+			runEnds[i] = i + 1
+		}
+	}
+
+	return words, runEnds
+}
+
+// Scans words in order, preferring the longest matching phrase at each word.
+func (v vocabulary) extract(fuzzy *fuzzyExtraction, text string) []string {
+	words, runEnds := splitWords(text)
+	normalizedWords := make([]string, len(words))
+	for i, word := range words {
+		normalizedWords[i] = strings.ToLower(word)
+	}
+
+	tokens := make([]string, 0, len(words))
+	for i := 0; i < len(words); {
+		token, numWords := v.longestMatch(normalizedWords[i:runEnds[i]])
+		if token == "" && words[i] != "" {
+			token = fuzzy.match(v, text, words[i])
+		}
+
+		if token != "" {
+			tokens = append(tokens, token)
+		}
+		i += numWords
+	}
+
+	return tokens
+}
+
+// Returns an extractor of the tokens for every word or phrase in text that is
+// an alias in tokensByAlias, in order of appearance.
+// Invalid aliases are ignored.
+func NewVocabularyExtractor(tokensByAlias map[string]string) func(string) []string {
+	vocab := newVocabularyUnchecked(tokensByAlias)
+
+	return func(text string) []string {
+		return vocab.extract(nil, text)
+	}
+}
+
+// As NewVocabularyExtractor, additionally matching misspelled single-word
+// aliases. onMatch, if not nil, is called for every fuzzy match.
+func NewFuzzyVocabularyExtractor(
+	tokensByAlias map[string]string, options FuzzyOptions, onMatch func(FuzzyMatch),
+) func(string) []string {
+	vocab := newVocabularyUnchecked(tokensByAlias)
+	fuzzy := &fuzzyExtraction{options: options, onMatch: onMatch}
+
+	return func(text string) []string {
+		return vocab.extract(fuzzy, text)
+	}
+}
+
+func newVocabularyUnchecked(tokensByAlias map[string]string) vocabulary {
+	vocab := vocabulary{
+		tokensByAlias: make(map[string]string, len(tokensByAlias)),
+		maxAliasWords: 1,
+	}
+	for alias, token := range tokensByAlias {
+		normalizedAlias := normalizeAlias(alias)
+		vocab.tokensByAlias[normalizedAlias] = token
+		if numWords := strings.Count(normalizedAlias, " ") + 1; numWords > vocab.maxAliasWords {
+			vocab.maxAliasWords = numWords
+		}
+	}
+
+	return vocab
+}
+
+//go:embed vocabularies/*.json
+var builtInVocabularyFiles embed.FS
+
+// From the vocabularies directory, in the same format as VocabularyRegistry
+// files.
+var builtInVocabularies = func() map[string]vocabulary {
+	dir, err := fs.Sub(builtInVocabularyFiles, "vocabularies")
+	if err != nil {
+		panic(err)
+	}
+	vocabulariesByName, err := readVocabularies(dir)
+	if err != nil {
+		panic(err)
+	}
+
+	return vocabulariesByName
+}()
+
+// Configurable extractors, with their default configuration.
+var extractorsByName = map[string]func(string) []string{
+	ChoiceExtractorName:    NewChoiceExtractor(DefaultChoices),
+	RatingExtractorName:    NewRatingExtractor(DefaultRatingScale),
+	WordCloudExtractorName: NewWordCloudExtractor(DefaultWordCloudOptions),
+}
+
+// Looks up built-in extractors. Built-in vocabularies are matched fuzzily if
+// fuzzy is not nil.
+func ExtractorByName(
+	name string, fuzzy *FuzzyOptions, onFuzzyMatch func(FuzzyMatch),
+) (func(string) []string, bool) {
+	if vocab, ok := builtInVocabularies[name]; ok {
+		var fuzzyMatching *fuzzyExtraction
+		if fuzzy != nil {
+			fuzzyMatching = &fuzzyExtraction{options: *fuzzy, onMatch: onFuzzyMatch}
+		}
+		return func(text string) []string {
+			return vocab.extract(fuzzyMatching, text)
+		}, true
+	}
+	if fuzzy != nil {
+		return nil, false
+	}
+	extractor, ok := extractorsByName[name]
+
+	return extractor, ok
+}
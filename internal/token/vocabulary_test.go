@@ -0,0 +1,39 @@
+package token
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestVocabularyPhrases(t *testing.T) {
+	extract := NewVocabularyExtractor(map[string]string{
+		"react":        "React",
+		"native":       "Native",
+		"react native": "React Native",
+		"c":            "C",
+		"objective c":  "Objective-C",
+		"visual basic": "Visual Basic",
+	})
+
+	for _, test := range []struct {
+		name string
+		text string
+		want []string
+	}{
+		{name: "phrase wins over its words", text: "react native", want: []string{"React Native"}},
+		{name: "any whitespace and case", text: "I use  React\tNative", want: []string{"React Native"}},
+		{name: "words outside phrases", text: "react or native", want: []string{"React", "Native"}},
+		{name: "in order of appearance", text: "c then objective c", want: []string{"C", "Objective-C"}},
+		{name: "not across commas", text: "react, native", want: []string{"React", "Native"}},
+		{name: "not across spaced punctuation", text: "react / native", want: []string{"React", "Native"}},
+		{name: "not across sentences", text: "objective. c", want: []string{"C"}},
+		{name: "incomplete phrase", text: "visual", want: []string{}},
+		{name: "phrase after punctuation", text: "hmm... visual basic!", want: []string{"Visual Basic"}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			if got := extract(test.text); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("extracted %v from %q, want %v", got, test.text, test.want)
+			}
+		})
+	}
+}
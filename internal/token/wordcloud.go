@@ -0,0 +1,129 @@
+package token
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const WordCloudExtractorName = "word-cloud"
+
+type WordCloudOptions struct {
+	// Language of the stop-words to ignore, and of the stemmer (default "english")
+	Language string `json:"language,omitempty"`
+	// Merge inflections of the same word (e.g. "testing" and "tests" both become "test")
+	Stem bool `json:"stem,omitempty"`
+	// Shorter words are ignored (default 3)
+	MinLength int `json:"minLength,omitempty"`
+}
+
+var DefaultWordCloudOptions = WordCloudOptions{Language: "english", MinLength: 3}
+
+var stemmersByLanguage = map[string]func(string) string{
+	"english": stemEnglish,
+}
+
+func (o WordCloudOptions) withDefaults() WordCloudOptions {
+	if o.Language == "" {
+		o.Language = DefaultWordCloudOptions.Language
+	}
+	if o.MinLength == 0 {
+		o.MinLength = DefaultWordCloudOptions.MinLength
+	}
+
+	return o
+}
+
+func (o WordCloudOptions) Validate() error {
+	o = o.withDefaults()
+	if _, ok := stopWordsByLanguage[o.Language]; !ok {
+		return fmt.Errorf(`no stop-words for language "%s"`, o.Language)
+	}
+	if _, ok := stemmersByLanguage[o.Language]; o.Stem && !ok {
+		return fmt.Errorf(`no stemmer for language "%s"`, o.Language)
+	}
+	if o.MinLength < 1 {
+		return fmt.Errorf("minLength must be at least 1")
+	}
+
+	return nil
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '\'' || r == '’'
+}
+
+func hasLetter(word string) bool {
+	return strings.IndexFunc(word, unicode.IsLetter) >= 0
+}
+
+// Ends in a repeated consonant, e.g. "runn" from "running".
+func endsInDoubleConsonant(word string) bool {
+	n := len(word)
+	return n >= 2 && word[n-1] == word[n-2] && !strings.ContainsRune("aeiouslz", rune(word[n-1]))
+}
+
+func hasVowel(word string) bool {
+	return strings.ContainsAny(word, "aeiouy")
+}
+
+// A deliberately simple suffix-stripping stemmer, which only needs to map
+// common inflections of a word to the same token.
+func stemEnglish(word string) string {
+	switch {
+	case strings.HasSuffix(word, "sses"):
+		return strings.TrimSuffix(word, "es")
+	case strings.HasSuffix(word, "ies") && len(word) > 4:
+		return strings.TrimSuffix(word, "ies") + "y"
+	case strings.HasSuffix(word, "ing") && len(word) > 5 && hasVowel(word[:len(word)-3]):
+		word = strings.TrimSuffix(word, "ing")
+	case strings.HasSuffix(word, "ed") && len(word) > 4 && hasVowel(word[:len(word)-2]):
+		word = strings.TrimSuffix(word, "ed")
+	case strings.HasSuffix(word, "s") && len(word) > 3 &&
+		!strings.HasSuffix(word, "ss") && !strings.HasSuffix(word, "us"):
+		return strings.TrimSuffix(word, "s")
+	default:
+		return word
+	}
+	if endsInDoubleConsonant(word) {
+		word = word[:len(word)-1]
+	}
+
+	return word
+}
+
+// Extracts every word that is not a stop-word, once per message, in order.
+// Words are lowercase, and stemmed if options.Stem is set.
+func NewWordCloudExtractor(options WordCloudOptions) func(string) []string {
+	options = options.withDefaults()
+	stopWords := stopWordsByLanguage[options.Language]
+	var stem func(string) string
+	if options.Stem {
+		stem = stemmersByLanguage[options.Language]
+	}
+
+	return func(text string) []string {
+		words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool { return !isWordRune(r) })
+		tokens := make([]string, 0, len(words))
+		seen := make(map[string]struct{}, len(words))
+		for _, word := range words {
+			word = strings.Trim(strings.ReplaceAll(word, "’", "'"), "'")
+			if utf8.RuneCountInString(word) < options.MinLength || !hasLetter(word) {
+				continue
+			}
+			if _, stopWord := stopWords[word]; stopWord {
+				continue
+			}
+			if stem != nil {
+				word = stem(word)
+			}
+			if _, duplicate := seen[word]; !duplicate {
+				seen[word] = struct{}{}
+				tokens = append(tokens, word)
+			}
+		}
+
+		return tokens
+	}
+}
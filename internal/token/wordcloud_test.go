@@ -0,0 +1,120 @@
+package token
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestStemEnglish(t *testing.T) {
+	for word, want := range map[string]string{
+		"testing":   "test",
+		"tests":     "test",
+		"tested":    "test",
+		"running":   "run",
+		"stopped":   "stop",
+		"filled":    "fill",
+		"fizzing":   "fizz",
+		"classes":   "class",
+		"libraries": "library",
+		"ties":      "tie",
+		"status":    "status",
+		"glass":     "glass",
+		"sing":      "sing",
+		"bred":      "bred",
+		"gas":       "gas",
+		"go":        "go",
+	} {
+		if stem := stemEnglish(word); stem != want {
+			t.Errorf("stemmed %q to %q, want %q", word, stem, want)
+		}
+	}
+}
+
+func TestWordCloudExtractor(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		options WordCloudOptions
+		text    string
+		want    []string
+	}{
+		{
+			name: "lowercase words in order, without stop-words",
+			text: "The Gophers love Rust and Go",
+			want: []string{"gophers", "love", "rust"},
+		},
+		{
+			name: "once per message",
+			text: "tests, more TESTS!",
+			want: []string{"tests"},
+		},
+		{
+			name: "apostrophes",
+			text: "Don’t panic, it's 'fine'",
+			want: []string{"panic", "fine"},
+		},
+		{
+			name: "numbers without letters",
+			text: "2024 was the year of go1",
+			want: []string{"year", "go1"},
+		},
+		{
+			name: "non-Latin letters",
+			text: "naïve café über",
+			want: []string{"naïve", "café", "über"},
+		},
+		{
+			name:    "stemmed",
+			options: WordCloudOptions{Stem: true},
+			text:    "testing tests tested",
+			want:    []string{"test"},
+		},
+		{
+			name:    "minimum length",
+			options: WordCloudOptions{MinLength: 5},
+			text:    "small cloud words",
+			want:    []string{"small", "cloud", "words"},
+		},
+		{
+			name:    "shorter than minimum length",
+			options: WordCloudOptions{MinLength: 6},
+			text:    "small cloud thinking",
+			want:    []string{"thinking"},
+		},
+		{
+			name:    "spanish stop-words",
+			options: WordCloudOptions{Language: "spanish"},
+			text:    "el código es muy rápido",
+			want:    []string{"código", "rápido"},
+		},
+		{
+			name: "only stop-words",
+			text: "what is it?",
+			want: []string{},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			extract := NewWordCloudExtractor(test.options)
+			if got := extract(test.text); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("extracted %v from %q, want %v", got, test.text, test.want)
+			}
+		})
+	}
+}
+
+func TestWordCloudOptionsValidate(t *testing.T) {
+	for _, test := range []struct {
+		options WordCloudOptions
+		valid   bool
+	}{
+		{options: WordCloudOptions{}, valid: true},
+		{options: WordCloudOptions{Language: "spanish"}, valid: true},
+		{options: WordCloudOptions{Language: "english", Stem: true}, valid: true},
+		{options: WordCloudOptions{Language: "spanish", Stem: true}, valid: false},
+		{options: WordCloudOptions{Language: "klingon"}, valid: false},
+		{options: WordCloudOptions{MinLength: -1}, valid: false},
+	} {
+		if err := test.options.Validate(); (err == nil) != test.valid {
+			t.Errorf("%+v returned %v, want valid: %t", test.options, err, test.valid)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package transcription
 
 import (
+	"context"
 	"log"
 	"presentation-service/internal/notification"
 	"sync"
@@ -9,38 +10,36 @@ import (
 type Broadcaster struct {
 	text         string
 	mutex        sync.RWMutex
-	notification *notification.Notification[Transcript]
+	notification *notification.SequencedNotification[Transcript]
 }
 
 func (b *Broadcaster) NewTranscriptionText(text string) {
 	log.Printf("Got transcription text: %v", text)
-	transcript := Transcript{Text: text}
-	b.notification.NotifyAll(transcript)
-
 	b.mutex.Lock()
 	defer b.mutex.Unlock()
 	b.text = text
+	b.notification.NotifyAll(Transcript{Text: text})
 }
 
-func (b *Broadcaster) Subscribe(subscriber chan<- Transcript) {
-	go func() {
-		b.mutex.RLock()
-		defer b.mutex.RUnlock()
-		subscriber <- Transcript{Text: b.text}
-	}()
-
-	numSubs := b.notification.Subscribe(subscriber)
-	log.Printf("+1 transcription subscriber (=%d)", numSubs)
-}
+// The current text is sent first, unless afterSeq is the latest.
+func (b *Broadcaster) Subscribe(
+	ctx context.Context, policy notification.DeliveryPolicy, afterSeq uint64,
+) (<-chan notification.Sequenced[Transcript], error) {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+	subscription, err := b.notification.SubscribeWithCurrent(
+		ctx, policy, afterSeq, func() Transcript { return Transcript{Text: b.text} },
+	)
+	if err != nil {
+		return nil, err
+	}
 
-func (b *Broadcaster) Unsubscribe(subscriber chan<- Transcript) {
-	numSubs := b.notification.Unsubscribe(subscriber)
-	log.Printf("-1 transcription subscriber (=%d)", numSubs)
+	return notification.LogSubscriber(b.notification, "transcription subscriber", subscription), nil
 }
 
 func NewBroadcaster() *Broadcaster {
 	return &Broadcaster{
 		text:         "",
-		notification: notification.NewNotification[Transcript](),
+		notification: notification.NewSequencedNotification[Transcript](1),
 	}
 }